@@ -0,0 +1,71 @@
+package config
+
+import (
+	"claude2api/logger"
+	"fmt"
+)
+
+// APIKeyDefinition 定义一个额外的 API 密钥及其默认参数
+type APIKeyDefinition struct {
+	Key string `json:"key"`
+	// 密钥的名称，用于日志和统计
+	Name string `json:"name,omitempty"`
+	// 发送给 claude.ai 的时区和语言，为空时使用全局配置
+	Timezone string `json:"timezone,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+}
+
+// 读取 API_KEYS 环境变量，可以是 JSON 数组或 JSON 文件路径
+func loadAPIKeys() []APIKeyDefinition {
+	keys, err := loadJSONEnv[APIKeyDefinition]("API_KEYS")
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load API keys: %v", err))
+	}
+	valid := keys[:0]
+	for _, key := range keys {
+		if key.Key == "" {
+			logger.Warn(fmt.Sprintf("Ignoring API key %s without a key", key.Name))
+			continue
+		}
+		if key.Name == "" {
+			key.Name = MaskKey(key.Key)
+		}
+		valid = append(valid, key)
+	}
+	return valid
+}
+
+// MaskKey 只保留密钥的首尾字符，避免在日志和统计中泄露
+func MaskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
+
+// GetAPIKey 查找 API 密钥，APIKEY 对应名称为 default 且使用全局配置的密钥
+func (c *Config) GetAPIKey(key string) (APIKeyDefinition, bool) {
+	if key == "" {
+		return APIKeyDefinition{}, false
+	}
+	if c.APIKey != "" && key == c.APIKey {
+		return APIKeyDefinition{Key: key, Name: "default"}, true
+	}
+	if c.AdminKey != "" && key == c.AdminKey {
+		return APIKeyDefinition{Key: key, Name: "admin"}, true
+	}
+	for _, definition := range c.APIKeys {
+		if definition.Key == key {
+			return definition, true
+		}
+	}
+	return APIKeyDefinition{}, false
+}
+
+// IsAdminKey 判断密钥能否访问管理接口，未设置 ADMIN_KEY 时只接受 APIKEY
+func (c *Config) IsAdminKey(key string) bool {
+	if c.AdminKey != "" {
+		return key == c.AdminKey
+	}
+	return c.APIKey != "" && key == c.APIKey
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"claude2api/logger"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -10,12 +13,45 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
 
+// 未配置时发送给 claude.ai 的时区和语言
+const (
+	DefaultTimezone = "America/New_York"
+	DefaultLocale   = "zh-CN"
+)
+
 type SessionInfo struct {
 	SessionKey string
 	OrgID      string
+	Proxy      string
+	// Org 为配置中按名称或 UUID 选择的组织，* 表示展开为每个组织一个会话
+	Org string
+}
+
+// ID identifies the pool entry, a session key may appear once per organization
+func (s SessionInfo) ID() string {
+	if s.Org == "" {
+		return s.SessionKey
+	}
+	return s.SessionKey + "/" + s.Org
+}
+
+// Fingerprint is the hash of ID() under which the session is persisted, so
+// the store never holds raw session keys
+func (s SessionInfo) Fingerprint() string {
+	sum := sha256.Sum256([]byte(s.ID()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Label is the masked ID used in logs, reports and diagnostics
+func (s SessionInfo) Label() string {
+	if s.Org == "" {
+		return MaskKey(s.SessionKey)
+	}
+	return MaskKey(s.SessionKey) + "/" + s.Org
 }
 
 type SessionRagen struct {
@@ -26,15 +62,43 @@ type SessionRagen struct {
 type Config struct {
 	Sessions               []SessionInfo
 	Address                string
+	ShutdownTimeout        time.Duration
+	StorePath              string
+	UsageRetention         time.Duration
+	SessionCheckInterval   time.Duration
+	RequireValidSession    bool
+	UploadCacheTTL         time.Duration
 	APIKey                 string
+	APIKeys                []APIKeyDefinition
+	AdminKey               string
 	Proxy                  string
+	ProxyPool              *ProxyPool
+	ProxyCheckInterval     time.Duration
+	ProxyPoolFallback      bool
 	ChatDelete             bool
+	ConversationPrefix     string
+	SweepInterval          time.Duration
+	SweepMaxAge            time.Duration
 	MaxChatHistoryLength   int
+	ContextStrategy        string
+	ContextMaxTokens       int
+	ContextKeepMessages    int
+	ContextSplitTokens     int
 	RetryCount             int
 	NoRolePrefix           bool
 	PromptDisableArtifacts bool
+	PromptTemplate         string
 	EnableMirrorApi        bool
 	MirrorApiPrefix        string
+	WebSearch              bool
+	StreamAnnotations      bool
+	AbortOnTruncate        bool
+	ModelAliases           []ModelAlias
+	Projects               []ProjectDefinition
+	Style                  string
+	Styles                 []StyleDefinition
+	Timezone               string
+	Locale                 string
 	RwMutx                 sync.RWMutex
 }
 
@@ -51,15 +115,24 @@ func parseSessionEnv(envValue string) (int, []SessionInfo) {
 			retryCount--
 			continue
 		}
+		// 会话可以通过 @ 绑定专属代理，例如 sk-ant-sid01-xxx:orgid@socks5://host:1080
+		proxy := ""
+		if at := strings.Index(pair, "@"); at >= 0 {
+			pair, proxy = pair[:at], pair[at+1:]
+		}
 		parts := strings.Split(pair, ":")
 		session := SessionInfo{
 			SessionKey: parts[0],
+			Proxy:      proxy,
 		}
 
+		// 组织可以是 UUID、组织名称或 *，名称和 * 在首次使用时解析
 		if len(parts) > 1 {
-			session.OrgID = parts[1]
-		} else if len(parts) == 1 {
-			session.OrgID = ""
+			if _, err := uuid.Parse(parts[1]); err == nil {
+				session.OrgID = parts[1]
+			} else {
+				session.Org = parts[1]
+			}
 		}
 
 		sessions = append(sessions, session)
@@ -72,31 +145,83 @@ func parseSessionEnv(envValue string) (int, []SessionInfo) {
 
 // 根据模型选择合适的 session
 func (c *Config) GetSessionForModel(idx int) (SessionInfo, error) {
+	c.RwMutx.RLock()
+	defer c.RwMutx.RUnlock()
 	if len(c.Sessions) == 0 || idx < 0 || idx >= len(c.Sessions) {
 		return SessionInfo{}, fmt.Errorf("invalid session index: %d", idx)
 	}
+	return c.Sessions[idx], nil
+}
+
+// SessionPool returns a copy of the session pool and the retry count taken
+// together, so callers keep a consistent view while sessions are expanded
+func (c *Config) SessionPool() ([]SessionInfo, int) {
 	c.RwMutx.RLock()
 	defer c.RwMutx.RUnlock()
-	return c.Sessions[idx], nil
+	return append([]SessionInfo{}, c.Sessions...), c.RetryCount
 }
 
-func (c *Config) SetSessionOrgID(sessionKey, orgID string) {
+func (c *Config) SetSessionOrgID(sessionID, orgID string) {
 	c.RwMutx.Lock()
 	defer c.RwMutx.Unlock()
 	for i, session := range c.Sessions {
-		if session.SessionKey == sessionKey {
-			logger.Info(fmt.Sprintf("Setting OrgID for session %s to %s", sessionKey, orgID))
+		if session.ID() == sessionID {
+			logger.Info(fmt.Sprintf("Setting OrgID for session %s to %s", session.Label(), orgID))
 			c.Sessions[i].OrgID = orgID
 			return
 		}
 	}
 }
+
+// ExpandSession replaces the pool entry with one entry per organization
+func (c *Config) ExpandSession(sessionID string, expanded []SessionInfo) {
+	c.RwMutx.Lock()
+	defer c.RwMutx.Unlock()
+	for i, session := range c.Sessions {
+		if session.ID() == sessionID {
+			sessions := append([]SessionInfo{}, c.Sessions[:i]...)
+			sessions = append(sessions, expanded...)
+			c.Sessions = append(sessions, c.Sessions[i+1:]...)
+			break
+		}
+	}
+	c.RetryCount = len(c.Sessions)
+	if c.RetryCount > 5 {
+		c.RetryCount = 5 // 限制最大重试次数为 5 次
+	}
+}
+
+// ErrNoHealthyProxy is returned when every proxy in PROXY_POOL is down
+var ErrNoHealthyProxy = errors.New("no healthy proxy in pool")
+
+// ProxyForSession 返回会话应使用的代理：会话专属代理 > 代理池 > 全局代理。
+// 代理池全部不可用时返回错误，设置 PROXY_POOL_FALLBACK 后才改用全局代理（可能为直连）
+func (c *Config) ProxyForSession(session SessionInfo) (string, error) {
+	if session.Proxy != "" {
+		return session.Proxy, nil
+	}
+	if c.ProxyPool != nil && c.ProxyPool.Len() > 0 {
+		if proxy := c.ProxyPool.Pick(session.SessionKey); proxy != "" {
+			return proxy, nil
+		}
+		if !c.ProxyPoolFallback {
+			return "", ErrNoHealthyProxy
+		}
+		logger.Warn("No healthy proxy in pool, falling back to global proxy")
+	}
+	return c.Proxy, nil
+}
+
 func (sr *SessionRagen) NextIndex() int {
 	sr.Mutex.Lock()
 	defer sr.Mutex.Unlock()
 
-	index := sr.Index
-	sr.Index = (index + 1) % len(ConfigInstance.Sessions)
+	sessions, _ := ConfigInstance.SessionPool()
+	if len(sessions) == 0 {
+		return 0
+	}
+	index := sr.Index % len(sessions)
+	sr.Index = (index + 1) % len(sessions)
 	return index
 }
 
@@ -106,31 +231,135 @@ func LoadConfig() *Config {
 	if err != nil {
 		maxChatHistoryLength = 10000 // 默认值
 	}
+	contextMaxTokens, err := strconv.Atoi(os.Getenv("CONTEXT_MAX_TOKENS"))
+	if err != nil {
+		contextMaxTokens = 0 // 默认按 MAX_CHAT_HISTORY_LENGTH 的字节数判断
+	}
+	contextKeepMessages, err := strconv.Atoi(os.Getenv("CONTEXT_KEEP_MESSAGES"))
+	if err != nil {
+		contextKeepMessages = 4 // 默认值
+	}
+	contextSplitTokens, err := strconv.Atoi(os.Getenv("CONTEXT_SPLIT_TOKENS"))
+	if err != nil || contextSplitTokens <= 0 {
+		contextSplitTokens = 30000 // 默认值
+	}
+	contextStrategy := os.Getenv("CONTEXT_STRATEGY")
+	if contextStrategy == "" {
+		contextStrategy = "attachment"
+	}
 	retryCount, sessions := parseSessionEnv(os.Getenv("SESSIONS"))
+	proxyCheckInterval, err := time.ParseDuration(os.Getenv("PROXY_CHECK_INTERVAL"))
+	if err != nil {
+		proxyCheckInterval = time.Minute // 默认值
+	}
+	sweepInterval, err := time.ParseDuration(os.Getenv("SWEEP_INTERVAL"))
+	if err != nil {
+		sweepInterval = time.Hour // 默认值，为 0 时关闭定时清理
+	}
+	sweepMaxAge, err := time.ParseDuration(os.Getenv("SWEEP_MAX_AGE"))
+	if err != nil {
+		sweepMaxAge = time.Hour // 默认值
+	}
+	shutdownTimeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil {
+		shutdownTimeout = 30 * time.Second // 默认值
+	}
+	usageRetention, err := time.ParseDuration(os.Getenv("USAGE_RETENTION"))
+	if err != nil {
+		usageRetention = 30 * 24 * time.Hour // 默认值，为 0 时永久保留
+	}
+	uploadCacheTTL, err := time.ParseDuration(os.Getenv("UPLOAD_CACHE_TTL"))
+	if err != nil {
+		uploadCacheTTL = 0 // 默认不复用已上传的文件
+	}
+	sessionCheckInterval, err := time.ParseDuration(os.Getenv("SESSION_CHECK_INTERVAL"))
+	if err != nil {
+		sessionCheckInterval = 30 * time.Minute // 默认值，为 0 时只在启动时检查
+	}
+	proxyCheckURL := os.Getenv("PROXY_CHECK_URL")
+	if proxyCheckURL == "" {
+		proxyCheckURL = "https://claude.ai"
+	}
 	config := &Config{
 		// 解析 SESSIONS 环境变量
 		Sessions: sessions,
 		// 设置服务地址，默认为 "0.0.0.0:8080"
 		Address: os.Getenv("ADDRESS"),
+		// 设置退出时等待进行中请求和对话删除的时间
+		ShutdownTimeout: shutdownTimeout,
+		// 设置持久化存储的文件路径，为空时保存在内存中
+		StorePath: os.Getenv("STORE_PATH"),
+		// 设置用量记录的保留时间
+		UsageRetention: usageRetention,
+		// 设置已上传文件的复用时间
+		UploadCacheTTL: uploadCacheTTL,
+		// 设置会话检查间隔
+		SessionCheckInterval: sessionCheckInterval,
+		// 设置没有可用会话时是否拒绝启动
+		RequireValidSession: os.Getenv("REQUIRE_VALID_SESSION") == "true",
 
 		// 设置 API 认证密钥
 		APIKey: os.Getenv("APIKEY"),
+		// 设置额外的 API 密钥
+		APIKeys: loadAPIKeys(),
+		// 设置管理接口的密钥，为空时使用 APIKEY
+		AdminKey: os.Getenv("ADMIN_KEY"),
 		// 设置代理地址
 		Proxy: os.Getenv("PROXY"),
+		// 设置代理池
+		ProxyPool: NewProxyPool(parseProxyPoolEnv(os.Getenv("PROXY_POOL")), proxyCheckURL),
+		// 设置代理健康检查间隔
+		ProxyCheckInterval: proxyCheckInterval,
+		// 设置代理池全部不可用时是否改用全局代理
+		ProxyPoolFallback: os.Getenv("PROXY_POOL_FALLBACK") == "true",
 		//自动删除聊天
 		ChatDelete: os.Getenv("CHAT_DELETE") != "false",
+		// 设置创建对话时使用的名称，清理任务按此前缀识别残留的对话
+		ConversationPrefix: os.Getenv("CONVERSATION_PREFIX"),
+		// 设置残留对话的清理间隔
+		SweepInterval: sweepInterval,
+		// 设置对话创建多久后才会被清理
+		SweepMaxAge: sweepMaxAge,
 		// 设置最大聊天历史长度
 		MaxChatHistoryLength: maxChatHistoryLength,
+		// 设置提示词过长时的处理方式
+		ContextStrategy: contextStrategy,
+		// 设置提示词过长的 token 阈值，为 0 时使用 MaxChatHistoryLength
+		ContextMaxTokens: contextMaxTokens,
+		// 设置 keep_recent 策略保留在提示词中的消息数
+		ContextKeepMessages: contextKeepMessages,
+		// 设置 split 策略每个附件的 token 上限
+		ContextSplitTokens: contextSplitTokens,
 		// 设置重试次数
 		RetryCount: retryCount,
 		// 设置是否使用角色前缀
 		NoRolePrefix: os.Getenv("NO_ROLE_PREFIX") == "true",
 		// 设置是否使用提示词禁用artifacts
 		PromptDisableArtifacts: os.Getenv("PROMPT_DISABLE_ARTIFACTS") == "true",
+		// 设置提示词模板，可以是预设名称或模板文件路径
+		PromptTemplate: os.Getenv("PROMPT_TEMPLATE"),
 		// 设置是否启用镜像API
 		EnableMirrorApi: os.Getenv("ENABLE_MIRROR_API") == "true",
 		// 设置镜像API前缀
 		MirrorApiPrefix: os.Getenv("MIRROR_API_PREFIX"),
+		// 设置是否默认启用联网搜索
+		WebSearch: os.Getenv("WEB_SEARCH") != "false",
+		// 设置是否在流式响应中发送引用注释
+		StreamAnnotations: os.Getenv("STREAM_ANNOTATIONS") == "true",
+		// 设置输出被截断后是否通知 claude.ai 停止生成
+		AbortOnTruncate: os.Getenv("ABORT_ON_TRUNCATE") != "false",
+		// 设置模型别名
+		ModelAliases: loadModelAliases(),
+		// 设置 claude.ai 项目
+		Projects: loadProjects(),
+		// 设置默认回复风格，为空时使用 Normal
+		Style: os.Getenv("STYLE"),
+		// 设置自定义回复风格
+		Styles: loadStyles(),
+		// 设置发送给 claude.ai 的时区
+		Timezone: os.Getenv("TIMEZONE"),
+		// 设置发送给 claude.ai 的语言
+		Locale: os.Getenv("LOCALE"),
 		//设置读写锁
 		RwMutx: sync.RWMutex{},
 	}
@@ -139,6 +368,22 @@ func LoadConfig() *Config {
 	if config.Address == "" {
 		config.Address = "0.0.0.0:8080"
 	}
+	if config.Timezone == "" {
+		config.Timezone = DefaultTimezone
+	}
+	if config.Locale == "" {
+		config.Locale = DefaultLocale
+	}
+	if _, err := time.LoadLocation(config.Timezone); err != nil {
+		logger.Warn(fmt.Sprintf("Invalid TIMEZONE %s: %v", config.Timezone, err))
+	}
+	// 未指定模板时，NO_ROLE_PREFIX 对应 no_prefix 预设
+	if config.PromptTemplate == "" {
+		config.PromptTemplate = "default"
+		if config.NoRolePrefix {
+			config.PromptTemplate = "no_prefix"
+		}
+	}
 	return config
 }
 
@@ -157,15 +402,51 @@ func init() {
 	logger.Info("Loaded config:")
 	logger.Info(fmt.Sprintf("Max Retry count: %d", ConfigInstance.RetryCount))
 	for _, session := range ConfigInstance.Sessions {
-		logger.Info(fmt.Sprintf("Session: %s, OrgID: %s", session.SessionKey, session.OrgID))
+		logger.Info(fmt.Sprintf("Session: %s, OrgID: %s, Org: %s, Proxy: %s", session.Label(), session.OrgID, session.Org, RedactProxy(session.Proxy)))
 	}
 	logger.Info(fmt.Sprintf("Address: %s", ConfigInstance.Address))
+	logger.Info(fmt.Sprintf("ShutdownTimeout: %s", ConfigInstance.ShutdownTimeout))
+	logger.Info(fmt.Sprintf("StorePath: %s", ConfigInstance.StorePath))
+	logger.Info(fmt.Sprintf("UsageRetention: %s", ConfigInstance.UsageRetention))
+	logger.Info(fmt.Sprintf("UploadCacheTTL: %s", ConfigInstance.UploadCacheTTL))
+	logger.Info(fmt.Sprintf("SessionCheckInterval: %s", ConfigInstance.SessionCheckInterval))
+	logger.Info(fmt.Sprintf("RequireValidSession: %t", ConfigInstance.RequireValidSession))
 	logger.Info(fmt.Sprintf("APIKey: %s", ConfigInstance.APIKey))
-	logger.Info(fmt.Sprintf("Proxy: %s", ConfigInstance.Proxy))
+	for _, key := range ConfigInstance.APIKeys {
+		logger.Info(fmt.Sprintf("API key: %s", key.Name))
+	}
+	logger.Info(fmt.Sprintf("AdminKey: %s", MaskKey(ConfigInstance.AdminKey)))
+	logger.Info(fmt.Sprintf("Proxy: %s", RedactProxy(ConfigInstance.Proxy)))
+	logger.Info(fmt.Sprintf("ProxyPool size: %d", ConfigInstance.ProxyPool.Len()))
+	logger.Info(fmt.Sprintf("ProxyCheckInterval: %s", ConfigInstance.ProxyCheckInterval))
+	logger.Info(fmt.Sprintf("ProxyPoolFallback: %t", ConfigInstance.ProxyPoolFallback))
 	logger.Info(fmt.Sprintf("ChatDelete: %t", ConfigInstance.ChatDelete))
+	logger.Info(fmt.Sprintf("ConversationPrefix: %s", ConfigInstance.ConversationPrefix))
+	logger.Info(fmt.Sprintf("SweepInterval: %s", ConfigInstance.SweepInterval))
+	logger.Info(fmt.Sprintf("SweepMaxAge: %s", ConfigInstance.SweepMaxAge))
 	logger.Info(fmt.Sprintf("MaxChatHistoryLength: %d", ConfigInstance.MaxChatHistoryLength))
+	logger.Info(fmt.Sprintf("ContextStrategy: %s", ConfigInstance.ContextStrategy))
+	logger.Info(fmt.Sprintf("ContextMaxTokens: %d", ConfigInstance.ContextMaxTokens))
+	logger.Info(fmt.Sprintf("ContextKeepMessages: %d", ConfigInstance.ContextKeepMessages))
+	logger.Info(fmt.Sprintf("ContextSplitTokens: %d", ConfigInstance.ContextSplitTokens))
 	logger.Info(fmt.Sprintf("NoRolePrefix: %t", ConfigInstance.NoRolePrefix))
 	logger.Info(fmt.Sprintf("PromptDisableArtifacts: %t", ConfigInstance.PromptDisableArtifacts))
+	logger.Info(fmt.Sprintf("PromptTemplate: %s", ConfigInstance.PromptTemplate))
 	logger.Info(fmt.Sprintf("EnableMirrorApi: %t", ConfigInstance.EnableMirrorApi))
 	logger.Info(fmt.Sprintf("MirrorApiPrefix: %s", ConfigInstance.MirrorApiPrefix))
+	logger.Info(fmt.Sprintf("WebSearch: %t", ConfigInstance.WebSearch))
+	logger.Info(fmt.Sprintf("StreamAnnotations: %t", ConfigInstance.StreamAnnotations))
+	logger.Info(fmt.Sprintf("AbortOnTruncate: %t", ConfigInstance.AbortOnTruncate))
+	for _, alias := range ConfigInstance.ModelAliases {
+		logger.Info(fmt.Sprintf("Model alias: %s -> %s", alias.Name, alias.Model))
+	}
+	for _, project := range ConfigInstance.Projects {
+		logger.Info(fmt.Sprintf("Project: %s, docs: %d", project.Name, len(project.Files)))
+	}
+	logger.Info(fmt.Sprintf("Timezone: %s", ConfigInstance.Timezone))
+	logger.Info(fmt.Sprintf("Locale: %s", ConfigInstance.Locale))
+	logger.Info(fmt.Sprintf("Style: %s", ConfigInstance.Style))
+	for _, style := range ConfigInstance.Styles {
+		logger.Info(fmt.Sprintf("Custom style: %s", style.Name))
+	}
 }
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadJSONEnv 读取环境变量 name 中的 JSON 数组，值不以 [ 开头时视为 JSON 文件路径
+func loadJSONEnv[T any](name string) ([]T, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return []T{}, nil
+	}
+	data := []byte(value)
+	if !strings.HasPrefix(value, "[") {
+		fileData, err := os.ReadFile(value)
+		if err != nil {
+			return []T{}, fmt.Errorf("failed to read %s file %s: %w", name, value, err)
+		}
+		data = fileData
+	}
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return []T{}, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if items == nil {
+		return []T{}, nil
+	}
+	return items, nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"claude2api/logger"
+	"fmt"
+)
+
+// ModelAlias 定义一个对外暴露的模型别名及其默认参数
+type ModelAlias struct {
+	// 对外暴露的模型名称
+	Name string `json:"name"`
+	// 实际使用的 Claude 模型，可带 -think 后缀
+	Model string `json:"model"`
+	// 是否启用联网搜索，为空时使用全局配置
+	WebSearch *bool `json:"web_search,omitempty"`
+	// 提示词模板，预设名称或模板文件路径，为空时使用全局配置
+	PromptTemplate string `json:"prompt_template,omitempty"`
+	// 提示词过长时的处理方式，为空时使用全局配置
+	ContextStrategy string `json:"context_strategy,omitempty"`
+	// 在指定的项目中创建对话，值为 PROJECTS 中的项目名或项目 UUID
+	Project string `json:"project,omitempty"`
+	// 回复风格，STYLES 中的风格名或账号中的风格名，为空时使用全局配置
+	Style string `json:"style,omitempty"`
+}
+
+// 读取 MODEL_ALIASES 环境变量，可以是 JSON 数组或 JSON 文件路径
+func loadModelAliases() []ModelAlias {
+	aliases, err := loadJSONEnv[ModelAlias]("MODEL_ALIASES")
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load model aliases: %v", err))
+	}
+	for i, alias := range aliases {
+		if alias.Model == "" {
+			aliases[i].Model = alias.Name
+		}
+	}
+	return aliases
+}
+
+// GetModelAlias 查找模型别名，未配置时返回以模型名本身为别名的默认值
+func (c *Config) GetModelAlias(name string) ModelAlias {
+	for _, alias := range c.ModelAliases {
+		if alias.Name == name {
+			return alias
+		}
+	}
+	return ModelAlias{Name: name, Model: name}
+}
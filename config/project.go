@@ -0,0 +1,56 @@
+package config
+
+import (
+	"claude2api/logger"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ProjectDefinition 定义一个需要在每个会话账号中存在的 claude.ai 项目
+type ProjectDefinition struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Instructions string `json:"instructions,omitempty"`
+	// Docs 为知识库文档的文件路径，启动时读取，文件名作为文档名
+	Docs  []string     `json:"docs,omitempty"`
+	Files []ProjectDoc `json:"-"`
+}
+
+// ProjectDoc 为读取后的知识库文档
+type ProjectDoc struct {
+	FileName string
+	Content  string
+}
+
+// 读取 PROJECTS 环境变量，可以是 JSON 数组或 JSON 文件路径
+func loadProjects() []ProjectDefinition {
+	projects, err := loadJSONEnv[ProjectDefinition]("PROJECTS")
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load projects: %v", err))
+	}
+	for i, project := range projects {
+		for _, path := range project.Docs {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to read doc %s of project %s: %v", path, project.Name, err))
+				continue
+			}
+			projects[i].Files = append(projects[i].Files, ProjectDoc{
+				FileName: filepath.Base(path),
+				Content:  string(content),
+			})
+		}
+	}
+	return projects
+}
+
+// GetProject 按名称查找项目定义
+func (c *Config) GetProject(name string) (ProjectDefinition, bool) {
+	for _, project := range c.Projects {
+		if project.Name == name {
+			return project, true
+		}
+	}
+	return ProjectDefinition{}, false
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"claude2api/logger"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyPool 维护一组出口代理及其健康状态
+type ProxyPool struct {
+	proxies  []string
+	healthy  map[string]bool
+	checkURL string
+	mutex    sync.RWMutex
+}
+
+// 解析 PROXY_POOL 格式的环境变量，逗号分隔
+func parseProxyPoolEnv(envValue string) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(envValue, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if _, err := url.Parse(proxy); err != nil {
+			logger.Error("Ignoring invalid proxy in PROXY_POOL")
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
+
+// RedactProxy 隐藏代理地址中的密码，用于日志
+func RedactProxy(proxy string) string {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.User == nil {
+		return proxy
+	}
+	return proxyURL.Redacted()
+}
+
+// NewProxyPool creates a pool where every proxy starts out healthy
+func NewProxyPool(proxies []string, checkURL string) *ProxyPool {
+	healthy := make(map[string]bool, len(proxies))
+	for _, proxy := range proxies {
+		healthy[proxy] = true
+	}
+	return &ProxyPool{
+		proxies:  proxies,
+		healthy:  healthy,
+		checkURL: checkURL,
+	}
+}
+
+// Len returns the number of proxies in the pool
+func (p *ProxyPool) Len() int {
+	return len(p.proxies)
+}
+
+// Pick returns the proxy bound to the session key.
+// Rendezvous hashing keeps the choice stable while the pool is healthy,
+// and only moves the sessions whose proxy went down.
+func (p *ProxyPool) Pick(sessionKey string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	best := ""
+	var bestScore uint64
+	for _, proxy := range p.proxies {
+		if !p.healthy[proxy] {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(sessionKey))
+		h.Write([]byte(proxy))
+		if score := h.Sum64(); best == "" || score > bestScore {
+			best, bestScore = proxy, score
+		}
+	}
+	return best
+}
+
+// CheckAll probes every proxy once and updates its health state
+func (p *ProxyPool) CheckAll() {
+	var wg sync.WaitGroup
+	for _, proxy := range p.proxies {
+		wg.Add(1)
+		go func(proxy string) {
+			defer wg.Done()
+			err := p.check(proxy)
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			if err != nil && p.healthy[proxy] {
+				logger.Warn(fmt.Sprintf("Proxy %s marked unhealthy: %v", RedactProxy(proxy), err))
+			} else if err == nil && !p.healthy[proxy] {
+				logger.Info(fmt.Sprintf("Proxy %s recovered", RedactProxy(proxy)))
+			}
+			p.healthy[proxy] = err == nil
+		}(proxy)
+	}
+	wg.Wait()
+}
+
+func (p *ProxyPool) check(proxy string) error {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// 每次检查使用新的连接，避免空闲连接随检查次数累积
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL), DisableKeepAlives: true},
+	}
+	resp, err := client.Get(p.checkURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// Cloudflare 拦截也说明代理本身可达，只有网关错误才认为代理不可用
+	if resp.StatusCode >= http.StatusBadGateway {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestExpandSession(t *testing.T) {
+	c := &Config{Sessions: []SessionInfo{{SessionKey: "a"}, {SessionKey: "b", Org: "*"}, {SessionKey: "c"}}, RetryCount: 3}
+	c.ExpandSession("b/*", []SessionInfo{
+		{SessionKey: "b", OrgID: "org-1", Org: "org-1"},
+		{SessionKey: "b", OrgID: "org-2", Org: "org-2"},
+		{SessionKey: "b", OrgID: "org-3", Org: "org-3"},
+	})
+	sessions, retryCount := c.SessionPool()
+	ids := []string{}
+	for _, session := range sessions {
+		ids = append(ids, session.ID())
+	}
+	expected := []string{"a", "b/org-1", "b/org-2", "b/org-3", "c"}
+	if len(ids) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, ids)
+		}
+	}
+	if retryCount != 5 {
+		t.Fatalf("expected retry count 5, got %d", retryCount)
+	}
+}
+
+func TestSessionPoolIsSnapshot(t *testing.T) {
+	c := &Config{Sessions: []SessionInfo{{SessionKey: "a", Org: "*"}}, RetryCount: 1}
+	sessions, _ := c.SessionPool()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.ExpandSession("a/*", []SessionInfo{{SessionKey: "a", Org: "x"}, {SessionKey: "a", Org: "y"}})
+		c.SetSessionOrgID("a/x", "org-x")
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			pool, retryCount := c.SessionPool()
+			if retryCount > len(pool) {
+				t.Errorf("retry count %d exceeds %d sessions", retryCount, len(pool))
+			}
+			c.GetSessionForModel(i % 3)
+		}
+	}()
+	wg.Wait()
+
+	if len(sessions) != 1 || sessions[0].ID() != "a/*" {
+		t.Fatalf("snapshot changed after expansion: %v", sessions)
+	}
+	if session, err := c.GetSessionForModel(0); err != nil || session.OrgID != "org-x" {
+		t.Fatalf("expected org-x, got %v %v", session, err)
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"claude2api/logger"
+	"fmt"
+	"strings"
+)
+
+// StyleDefinition 定义一个自定义回复风格，请求时作为 personalized_styles 发送
+type StyleDefinition struct {
+	Name    string `json:"name"`
+	Prompt  string `json:"prompt"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// 读取 STYLES 环境变量，可以是 JSON 数组或 JSON 文件路径
+func loadStyles() []StyleDefinition {
+	styles, err := loadJSONEnv[StyleDefinition]("STYLES")
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load styles: %v", err))
+	}
+	return styles
+}
+
+// GetStyle 按名称查找自定义风格，忽略大小写
+func (c *Config) GetStyle(name string) (StyleDefinition, bool) {
+	for _, style := range c.Styles {
+		if strings.EqualFold(style.Name, name) {
+			return style, true
+		}
+	}
+	return StyleDefinition{}, false
+}
@@ -1,9 +1,10 @@
 package core
 
 import (
-	"bufio"
+	"claude2api/config"
 	"claude2api/logger"
 	"claude2api/model"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -12,42 +13,35 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/imroc/req/v3"
 )
 
 type Client struct {
-	SessionKey   string
-	orgID        string
-	client       *req.Client
-	defaultAttrs map[string]interface{}
+	SessionKey string
+	orgID      string
+	client     *req.Client
 }
 
-type ResponseEvent struct {
-	Type  string `json:"type"`
-	Index int    `json:"index"`
-	Delta struct {
-		Type     string `json:"type"`
-		Text     string `json:"text"`
-		THINKING string `json:"thinking"`
-	} `json:"delta"`
-	Error struct {
-		Message string `json:"message"`
-	} `json:"error"`
+func NewClient(sessionKey string, proxy string) *Client {
+	return newClientWith(sessionKey, newHTTPClient(sessionKey, proxy))
 }
 
-func NewClient(sessionKey string, proxy string) *Client {
+// newHTTPClient builds the impersonated Chrome client used to talk to claude.ai
+func newHTTPClient(sessionKey string, proxy string) *req.Client {
 	client := req.C().ImpersonateChrome().SetTimeout(time.Minute * 5)
 	client.Transport.SetResponseHeaderTimeout(time.Second * 10)
+	client.Transport.SetIdleConnTimeout(time.Minute * 5)
+	client.Transport.MaxIdleConnsPerHost = 16
 	if proxy != "" {
 		client.SetProxyURL(proxy)
 	}
 	// Set common headers
 	headers := map[string]string{
 		"accept":                    "text/event-stream, text/event-stream",
-		"accept-language":           "zh-CN,zh;q=0.9",
+		"accept-language":           AcceptLanguage(config.DefaultLocale),
 		"anthropic-client-platform": "web_claude_ai",
 		"content-type":              "application/json",
 		"origin":                    "https://claude.ai",
@@ -61,36 +55,14 @@ func NewClient(sessionKey string, proxy string) *Client {
 		Name:  "sessionKey",
 		Value: sessionKey,
 	})
+	return client
+}
+
+func newClientWith(sessionKey string, client *req.Client) *Client {
 	// Create default client with session key
 	c := &Client{
 		SessionKey: sessionKey,
 		client:     client,
-		defaultAttrs: map[string]interface{}{
-			"personalized_styles": []map[string]interface{}{
-				{
-					"type":       "default",
-					"key":        "Default",
-					"name":       "Normal",
-					"nameKey":    "normal_style_name",
-					"prompt":     "Normal",
-					"summary":    "Default responses from Claude",
-					"summaryKey": "normal_style_summary",
-					"isDefault":  true,
-				},
-			},
-			"tools": []map[string]interface{}{
-				{
-					"type": "web_search_v0",
-					"name": "web_search",
-				},
-			},
-			"parent_message_uuid": "00000000-0000-4000-8000-000000000000",
-			"attachments":         []interface{}{},
-			"files":               []interface{}{},
-			"sync_sources":        []interface{}{},
-			"rendering_mode":      "messages",
-			"timezone":            "America/New_York",
-		},
 	}
 	return c
 }
@@ -99,59 +71,147 @@ func NewClient(sessionKey string, proxy string) *Client {
 func (c *Client) SetOrgID(orgID string) {
 	c.orgID = orgID
 }
-func (c *Client) GetOrgID() (string, error) {
+
+// OrgID returns the organization ID of the client
+func (c *Client) OrgID() string {
+	return c.orgID
+}
+
+// Organization is an organization the session belongs to
+type Organization struct {
+	ID            int      `json:"id"`
+	UUID          string   `json:"uuid"`
+	Name          string   `json:"name"`
+	RateLimitTier string   `json:"rate_limit_tier"`
+	Capabilities  []string `json:"capabilities"`
+}
+
+// ErrSessionExpired is returned when claude.ai rejects the session key
+var ErrSessionExpired = errors.New("session expired or invalid")
+
+// GetOrganizations lists the organizations of the session
+func (c *Client) GetOrganizations() ([]Organization, error) {
 	url := "https://claude.ai/api/organizations"
 	resp, err := c.client.R().
 		SetHeader("referer", "https://claude.ai/new").
 		Get(url)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	// 403 也可能是风控等临时拒绝，只有明确的会话失效错误才视为过期
+	if resp.StatusCode == http.StatusUnauthorized || (resp.StatusCode == http.StatusForbidden && invalidSession(resp.Bytes())) {
+		return nil, fmt.Errorf("%w: status code %d", ErrSessionExpired, resp.StatusCode)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
-	type OrgResponse []struct {
-		ID            int    `json:"id"`
-		UUID          string `json:"uuid"`
-		Name          string `json:"name"`
-		RateLimitTier string `json:"rate_limit_tier"`
+	var orgs []Organization
+	if err := json.Unmarshal(resp.Bytes(), &orgs); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	return orgs, nil
+}
 
-	var orgs OrgResponse
-	if err := json.Unmarshal(resp.Bytes(), &orgs); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+// invalidSession reports whether the error body says the session key is no
+// longer valid, e.g. {"error":{"details":{"error_code":"account_session_invalid"}}}
+func invalidSession(body []byte) bool {
+	var result struct {
+		Error struct {
+			Details struct {
+				ErrorCode string `json:"error_code"`
+			} `json:"details"`
+		} `json:"error"`
 	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false
+	}
+	return result.Error.Details.ErrorCode == "account_session_invalid"
+}
+
+// SelectOrganization picks the organization matching selector by UUID or
+// name. An empty selector picks the only organization, or the personal
+// claude.ai one. Errors list the available organizations.
+func SelectOrganization(orgs []Organization, selector string) (*Organization, error) {
 	if len(orgs) == 0 {
-		return "", errors.New("no organizations found")
+		return nil, errors.New("no organizations found")
+	}
+	if selector != "" {
+		for i := range orgs {
+			if orgs[i].UUID == selector || strings.EqualFold(orgs[i].Name, selector) {
+				return &orgs[i], nil
+			}
+		}
+		return nil, fmt.Errorf("organization %q not found, available: %s", selector, DescribeOrganizations(orgs))
 	}
 	if len(orgs) == 1 {
-		return orgs[0].UUID, nil
+		return &orgs[0], nil
 	}
-	for _, org := range orgs {
-		if org.RateLimitTier == "default_claude_ai" {
-			return org.UUID, nil
+	for i := range orgs {
+		if orgs[i].RateLimitTier == "default_claude_ai" {
+			return &orgs[i], nil
 		}
 	}
-	return "", errors.New("no default organization found")
+	return nil, fmt.Errorf("no default organization found, select one by name or UUID from: %s", DescribeOrganizations(orgs))
+}
+
+// DescribeOrganizations formats organizations as name (uuid, tier) for diagnostics
+func DescribeOrganizations(orgs []Organization) string {
+	descriptions := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s, %s)", org.Name, org.UUID, org.RateLimitTier))
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+func (c *Client) GetOrgID() (string, error) {
+	orgs, err := c.GetOrganizations()
+	if err != nil {
+		return "", err
+	}
+	org, err := SelectOrganization(orgs, "")
+	if err != nil {
+		return "", err
+	}
+	return org.UUID, nil
+}
+
+// ConversationOptions configures a new conversation
+type ConversationOptions struct {
+	Model string
+	// Thinking 开启扩展思考（paprika_mode），网页端只提供开关，没有思考预算
+	Thinking bool
+	// ProjectUUID 不为空时在该项目中创建对话
+	ProjectUUID string
+	// Name 为对话名称，清理任务按名称前缀识别本服务创建的对话
+	Name string
+}
 
+// ParseModelName splits the -think suffix from a model name
+func ParseModelName(model string) (string, bool) {
+	// 如果以-think结尾
+	if len(model) > 6 && model[len(model)-6:] == "-think" {
+		return model[:len(model)-6], true
+	}
+	return model, false
 }
 
 // CreateConversation creates a new conversation and returns its UUID
-func (c *Client) CreateConversation(model string) (string, error) {
+func (c *Client) CreateConversation(opts ConversationOptions) (string, error) {
 	if c.orgID == "" {
 		return "", errors.New("organization ID not set")
 	}
 	url := fmt.Sprintf("https://claude.ai/api/organizations/%s/chat_conversations", c.orgID)
-	// 如果以-think结尾
 	requestBody := map[string]interface{}{
-		"model":                            model,
+		"model":                            opts.Model,
 		"uuid":                             uuid.New().String(),
-		"name":                             "",
+		"name":                             opts.Name,
 		"include_conversation_preferences": true,
 	}
-	if len(model) > 6 && model[len(model)-6:] == "-think" {
+	if opts.Thinking {
 		requestBody["paprika_mode"] = "extended"
-		requestBody["model"] = model[:len(model)-6]
+	}
+	if opts.ProjectUUID != "" {
+		requestBody["project_uuid"] = opts.ProjectUUID
 	}
 	resp, err := c.client.R().
 		SetHeader("referer", "https://claude.ai/new").
@@ -160,6 +220,9 @@ func (c *Client) CreateConversation(model string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
 	}
+	if resp.StatusCode == http.StatusNotFound && opts.ProjectUUID != "" {
+		return "", fmt.Errorf("%w: %s", ErrProjectNotFound, opts.ProjectUUID)
+	}
 	if resp.StatusCode != http.StatusCreated {
 		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
@@ -175,117 +238,200 @@ func (c *Client) CreateConversation(model string) (string, error) {
 	return uuid, nil
 }
 
-// SendMessage sends a message to a conversation and returns the status and response
-func (c *Client) SendMessage(conversationID string, message string, stream bool, gc *gin.Context) (int, error) {
+// SendMessage sends the payload to a conversation and renders the reply with the writer
+func (c *Client) SendMessage(ctx context.Context, conversationID string, payload *CompletionPayload, writer model.CompletionWriter, opts ResponseOptions) (*CompletionResult, int, error) {
 	if c.orgID == "" {
-		return 500, errors.New("organization ID not set")
+		return nil, 500, errors.New("organization ID not set")
 	}
 	url := fmt.Sprintf("https://claude.ai/api/organizations/%s/chat_conversations/%s/completion",
 		c.orgID, conversationID)
-	// Create request body with default attributes
-	requestBody := c.defaultAttrs
-	requestBody["prompt"] = message
-	// Set up streaming response
+	// Create request body from the per-request payload
+	requestBody := payload.Build()
+	// Set up streaming response, the upstream request is cancelled with ctx
 	resp, err := c.client.R().DisableAutoReadResponse().
+		SetContext(ctx).
 		SetHeader("referer", fmt.Sprintf("https://claude.ai/chat/%s", conversationID)).
 		SetHeader("accept", "text/event-stream, text/event-stream").
 		SetHeader("anthropic-client-platform", "web_claude_ai").
 		SetHeader("cache-control", "no-cache").
+		SetHeader("accept-language", AcceptLanguage(payload.Locale())).
 		SetBody(requestBody).
 		Post(url)
 	if err != nil {
-		return 500, fmt.Errorf("request failed: %w", err)
+		return nil, 500, fmt.Errorf("request failed: %w", err)
 	}
 	logger.Info(fmt.Sprintf("Claude response status code: %d", resp.StatusCode))
 	if resp.StatusCode == http.StatusTooManyRequests {
-		return http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded")
+		resp.Body.Close()
+		return nil, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded")
 	}
 	if resp.StatusCode != http.StatusOK {
-		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		resp.Body.Close()
+		return nil, resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	result, err := c.HandleResponse(ctx, resp.Body, writer, opts)
+	if err == nil && result.Truncated && opts.AbortUpstream {
+		// 已停止读取上游，通知 claude.ai 停止继续生成
+		if err := c.StopResponse(conversationID); err != nil {
+			logger.Error(fmt.Sprintf("Failed to stop response: %v", err))
+		}
+	}
+	return result, 200, err
+}
+
+// StopResponse asks claude.ai to stop generating the reply of a conversation
+func (c *Client) StopResponse(conversationID string) error {
+	if c.orgID == "" {
+		return errors.New("organization ID not set")
+	}
+	url := fmt.Sprintf("https://claude.ai/api/organizations/%s/chat_conversations/%s/stop_response",
+		c.orgID, conversationID)
+	resp, err := c.client.R().
+		SetHeader("referer", fmt.Sprintf("https://claude.ai/chat/%s", conversationID)).
+		Post(url)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrConversationNotFound
 	}
-	return 200, c.HandleResponse(resp.Body, stream, gc)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
 }
 
-// HandleResponse converts Claude's SSE format to OpenAI format and writes to the response writer
-func (c *Client) HandleResponse(body io.ReadCloser, stream bool, gc *gin.Context) error {
+// HandleResponse decodes Claude's SSE stream, renders it with the writer and summarizes it.
+// Reading stops early once a stop sequence or the token budget in opts is reached.
+func (c *Client) HandleResponse(ctx context.Context, body io.ReadCloser, writer model.CompletionWriter, opts ResponseOptions) (*CompletionResult, error) {
 	defer body.Close()
-	// Set headers for streaming
-	if stream {
-		gc.Writer.Header().Set("Content-Type", "text/event-stream")
-		gc.Writer.Header().Set("Cache-Control", "no-cache")
-		gc.Writer.Header().Set("Connection", "keep-alive")
-		// 发送200状态码
-		gc.Writer.WriteHeader(http.StatusOK)
-		gc.Writer.Flush()
-	}
-	scanner := bufio.NewScanner(body)
-	clientDone := gc.Request.Context().Done()
-	// Keep track of the full response for the final message
-	thinkingShown := false
-	res_all_text := ""
-	for scanner.Scan() {
+	if err := writer.Start(); err != nil {
+		return nil, err
+	}
+	reader := NewEventReader(body)
+	limiter := newOutputLimiter(opts)
+	result := &CompletionResult{}
+	var blocks []*ContentBlock
+	var text, thinking strings.Builder
+	// 引用开始时记录其在正文中的位置，结束时生成注释
+	textLen := 0
+	openCitations := map[string]model.Annotation{}
+	writeText := func(delta string) {
+		if delta == "" {
+			return
+		}
+		text.WriteString(delta)
+		textLen += utf8.RuneCountInString(delta)
+		writer.WriteText(delta)
+	}
+	for limiter.stopReason == "" {
 		select {
-		case <-clientDone:
+		case <-ctx.Done():
 			// 客户端已断开连接，清理资源并退出
 			logger.Info("Client closed connection")
-			return nil
+			result.Aborted = true
+			result.finalize(blocks, &text, &thinking)
+			return result, nil
 		default:
 			// 继续处理响应
 		}
-		line := scanner.Text()
-		// logger.Info(fmt.Sprintf("Claude SSE line: %s", line))
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
 		}
-		data := line[6:]
-		var event ResponseEvent
-		if err := json.Unmarshal([]byte(data), &event); err == nil {
-			if event.Type == "error" && event.Error.Message != "" {
-				model.ReturnOpenAIResponse(event.Error.Message, stream, gc)
-				return nil
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %w", err)
+		}
+
+		switch event.Type {
+		case EventMessageStart:
+			if event.Message != nil {
+				result.MessageID = event.Message.UUID
+				result.Model = event.Message.Model
 			}
-			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
-				res_text := event.Delta.Text
-				if thinkingShown {
-					res_text = "</think>\n" + res_text
-					thinkingShown = false
-				}
-				res_all_text += res_text
-				if !stream {
-					continue
-				}
-				model.ReturnOpenAIResponse(res_text, stream, gc)
+		case EventContentBlockStart:
+			if event.ContentBlock == nil {
 				continue
 			}
-			if event.Delta.Type == "thinking_delta" {
-				res_text := event.Delta.THINKING
-				if !thinkingShown {
-					res_text = "<think>" + res_text
-					thinkingShown = true
+			block := *event.ContentBlock
+			blocks = append(blocks, &block)
+			if block.Type == BlockToolUse {
+				logger.Info(fmt.Sprintf("Claude used tool: %s", block.Name))
+				result.ToolsUsed = appendUnique(result.ToolsUsed, block.Name)
+			}
+		case EventContentBlockDelta:
+			if event.Delta == nil {
+				continue
+			}
+			switch event.Delta.Type {
+			case DeltaText:
+				writeText(limiter.text(event.Delta.Text))
+			case DeltaThinking:
+				if delta := limiter.thinking(event.Delta.Thinking); delta != "" {
+					thinking.WriteString(delta)
+					writer.WriteThinking(delta)
 				}
-				res_all_text += res_text
-				if !stream {
+			case DeltaInputJSON:
+				if len(blocks) > 0 {
+					block := blocks[len(blocks)-1]
+					block.Input = append(block.Input, event.Delta.PartialJSON...)
+				}
+			case DeltaCitationStart:
+				if citation := event.Delta.Citation; citation != nil {
+					openCitations[citation.UUID] = model.NewURLCitation(citation.URL, citation.Title, textLen)
+				}
+			case DeltaCitationEnd:
+				annotation, ok := openCitations[event.Delta.CitationUUID]
+				if !ok {
 					continue
 				}
-				model.ReturnOpenAIResponse(res_text, stream, gc)
-				continue
+				delete(openCitations, event.Delta.CitationUUID)
+				annotation.URLCitation.EndIndex = textLen
+				result.Annotations = append(result.Annotations, annotation)
+				writer.WriteAnnotation(annotation)
+			}
+		case EventMessageDelta:
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				result.StopReason = event.Delta.StopReason
+			}
+		case EventMessageLimit:
+			result.MessageLimit = event.MessageLimit
+		case EventError:
+			if event.Error != nil && event.Error.Message != "" {
+				result.Error = event.Error
+				writer.WriteError(event.Error.Message)
+				result.finalize(blocks, &text, &thinking)
+				return result, nil
 			}
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading response: %w", err)
-	}
-	if !stream {
-		model.ReturnOpenAIResponse(res_all_text, stream, gc)
-	} else {
-		// 发送结束标志
-		gc.Writer.Write([]byte("data: [DONE]\n\n"))
-		gc.Writer.Flush()
-	}
+	writeText(limiter.flush())
+	if limiter.stopReason != "" {
+		// 输出被截断，剩余的上游内容不再读取
+		logger.Info(fmt.Sprintf("Output truncated by %s", limiter.stopReason))
+		result.StopReason = limiter.stopReason
+		result.Truncated = true
+	}
+	result.finalize(blocks, &text, &thinking)
+	writer.Finish(model.CompletionInfo{
+		FinishReason: result.FinishReason(),
+		ToolsUsed:    result.ToolsUsed,
+	})
+	return result, nil
+}
 
-	return nil
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
 }
 
+// ErrConversationNotFound is returned when the conversation was already deleted
+var ErrConversationNotFound = errors.New("conversation not found")
+
 // DeleteConversation deletes a conversation by ID
 func (c *Client) DeleteConversation(conversationID string) error {
 	if c.orgID == "" {
@@ -303,26 +449,26 @@ func (c *Client) DeleteConversation(conversationID string) error {
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrConversationNotFound
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 	return nil
 }
 
-// UploadFile uploads files to Claude and adds them to the client's default attributes
+// UploadFile uploads files to Claude and returns their file UUIDs
 // fileData should be in the format: data:image/jpeg;base64,/9j/4AA...
-func (c *Client) UploadFile(fileData []string) error {
+func (c *Client) UploadFile(fileData []string) ([]string, error) {
 	if c.orgID == "" {
-		return errors.New("organization ID not set")
+		return nil, errors.New("organization ID not set")
 	}
 	if len(fileData) == 0 {
-		return errors.New("empty file data")
+		return nil, errors.New("empty file data")
 	}
 
-	// Initialize files array in default attributes if it doesn't exist
-	if _, ok := c.defaultAttrs["files"]; !ok {
-		c.defaultAttrs["files"] = []interface{}{}
-	}
+	fileUUIDs := []string{}
 
 	// Process each file
 	for _, fd := range fileData {
@@ -333,18 +479,18 @@ func (c *Client) UploadFile(fileData []string) error {
 		// Parse the base64 data
 		parts := strings.SplitN(fd, ",", 2)
 		if len(parts) != 2 {
-			return errors.New("invalid file data format")
+			return nil, errors.New("invalid file data format")
 		}
 
 		// Get the content type from the data URI
 		metaParts := strings.SplitN(parts[0], ":", 2)
 		if len(metaParts) != 2 {
-			return errors.New("invalid content type in file data")
+			return nil, errors.New("invalid content type in file data")
 		}
 
 		metaInfo := strings.SplitN(metaParts[1], ";", 2)
 		if len(metaInfo) != 2 || metaInfo[1] != "base64" {
-			return errors.New("invalid encoding in file data")
+			return nil, errors.New("invalid encoding in file data")
 		}
 
 		contentType := metaInfo[0]
@@ -352,7 +498,7 @@ func (c *Client) UploadFile(fileData []string) error {
 		// Decode the base64 data
 		fileBytes, err := base64.StdEncoding.DecodeString(parts[1])
 		if err != nil {
-			return fmt.Errorf("failed to decode base64 data: %w", err)
+			return nil, fmt.Errorf("failed to decode base64 data: %w", err)
 		}
 
 		// Determine filename based on content type
@@ -380,11 +526,11 @@ func (c *Client) UploadFile(fileData []string) error {
 			Post(url)
 
 		if err != nil {
-			return fmt.Errorf("request failed: %w", err)
+			return nil, fmt.Errorf("request failed: %w", err)
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, resp.String())
+			return nil, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, resp.String())
 		}
 
 		// Parse the response
@@ -393,28 +539,15 @@ func (c *Client) UploadFile(fileData []string) error {
 		}
 
 		if err := json.Unmarshal(resp.Bytes(), &result); err != nil {
-			return fmt.Errorf("failed to parse response: %w", err)
+			return nil, fmt.Errorf("failed to parse response: %w", err)
 		}
 
 		if result.FileUUID == "" {
-			return errors.New("file UUID not found in response")
+			return nil, errors.New("file UUID not found in response")
 		}
 
-		// Add file to default attributes
-		c.defaultAttrs["files"] = append(c.defaultAttrs["files"].([]interface{}), result.FileUUID)
-	}
-
-	return nil
-}
-
-func (c *Client) SetBigContext(context string) {
-	c.defaultAttrs["attachments"] = []map[string]interface{}{
-		{
-			"file_name":         "context.txt",
-			"file_type":         "text/plain",
-			"file_size":         len(context),
-			"extracted_content": context,
-		},
+		fileUUIDs = append(fileUUIDs, result.FileUUID)
 	}
 
+	return fileUUIDs, nil
 }
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Conversation is a conversation listed in the account
+type Conversation struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListConversations lists the conversations of the organization
+func (c *Client) ListConversations() ([]Conversation, error) {
+	if c.orgID == "" {
+		return nil, errors.New("organization ID not set")
+	}
+	url := fmt.Sprintf("https://claude.ai/api/organizations/%s/chat_conversations", c.orgID)
+	resp, err := c.client.R().
+		SetHeader("referer", "https://claude.ai/recents").
+		Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var conversations []Conversation
+	if err := json.Unmarshal(resp.Bytes(), &conversations); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return conversations, nil
+}
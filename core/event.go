@@ -0,0 +1,197 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"claude2api/model"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Event types sent by the claude.ai completion stream
+const (
+	EventMessageStart      = "message_start"
+	EventContentBlockStart = "content_block_start"
+	EventContentBlockDelta = "content_block_delta"
+	EventContentBlockStop  = "content_block_stop"
+	EventMessageDelta      = "message_delta"
+	EventMessageStop       = "message_stop"
+	EventMessageLimit      = "message_limit"
+	EventPing              = "ping"
+	EventError             = "error"
+)
+
+// Delta types carried by content_block_delta events
+const (
+	DeltaText          = "text_delta"
+	DeltaThinking      = "thinking_delta"
+	DeltaSignature     = "signature_delta"
+	DeltaInputJSON     = "input_json_delta"
+	DeltaCitationStart = "citation_start_delta"
+	DeltaCitationEnd   = "citation_end_delta"
+)
+
+// Content block types
+const (
+	BlockText       = "text"
+	BlockThinking   = "thinking"
+	BlockToolUse    = "tool_use"
+	BlockToolResult = "tool_result"
+)
+
+// StreamEvent is one decoded event of the completion stream
+type StreamEvent struct {
+	Type         string        `json:"type"`
+	Index        int           `json:"index"`
+	Message      *MessageInfo  `json:"message,omitempty"`
+	ContentBlock *ContentBlock `json:"content_block,omitempty"`
+	Delta        *EventDelta   `json:"delta,omitempty"`
+	MessageLimit *MessageLimit `json:"message_limit,omitempty"`
+	Error        *StreamError  `json:"error,omitempty"`
+}
+
+// MessageInfo is sent with message_start
+type MessageInfo struct {
+	ID    string `json:"id"`
+	UUID  string `json:"uuid"`
+	Model string `json:"model"`
+}
+
+// ContentBlock describes a block opened by content_block_start
+type ContentBlock struct {
+	Type string `json:"type"`
+	// tool_use 和 tool_result 专有字段
+	ID      string          `json:"id,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Input   json.RawMessage `json:"input,omitempty"`
+	Content json.RawMessage `json:"content,omitempty"`
+	IsError bool            `json:"is_error,omitempty"`
+}
+
+// EventDelta is the delta of content_block_delta and message_delta events
+type EventDelta struct {
+	Type         string    `json:"type"`
+	Text         string    `json:"text"`
+	Thinking     string    `json:"thinking"`
+	PartialJSON  string    `json:"partial_json"`
+	Citation     *Citation `json:"citation,omitempty"`
+	CitationUUID string    `json:"citation_uuid"`
+	// message_delta 专有字段
+	StopReason   string `json:"stop_reason"`
+	StopSequence string `json:"stop_sequence"`
+}
+
+// Citation is a web source referenced by the text between its start and end deltas
+type Citation struct {
+	UUID  string `json:"uuid"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// MessageLimit reports the rate limit state of the account
+type MessageLimit struct {
+	Type          string `json:"type"`
+	ResetsAt      int64  `json:"resetsAt"`
+	Remaining     *int   `json:"remaining"`
+	PerModelLimit *bool  `json:"perModelLimit"`
+}
+
+// StreamError is sent with error events
+type StreamError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// CompletionResult summarizes a finished completion stream
+type CompletionResult struct {
+	MessageID    string
+	Model        string
+	StopReason   string
+	MessageLimit *MessageLimit
+	Error        *StreamError
+	// Blocks 按顺序记录所有内容块，tool_use 的 Input 已拼接完整
+	Blocks      []ContentBlock
+	ToolsUsed   []string
+	Text        string
+	Thinking    string
+	Annotations []model.Annotation
+	// Aborted 表示客户端在流结束前断开了连接
+	Aborted bool
+	// Truncated 表示输出因停止序列或 token 上限被代理截断
+	Truncated bool
+}
+
+// FinishReason maps the upstream stop reason to an OpenAI finish_reason
+func (r *CompletionResult) FinishReason() string {
+	switch r.StopReason {
+	case "max_tokens":
+		return "length"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// RateLimited reports whether the account hit its message limit
+func (r *CompletionResult) RateLimited() bool {
+	return r.MessageLimit != nil && r.MessageLimit.Type == "exceeded_limit"
+}
+
+func (r *CompletionResult) finalize(blocks []*ContentBlock, text *strings.Builder, thinking *strings.Builder) {
+	for _, block := range blocks {
+		r.Blocks = append(r.Blocks, *block)
+	}
+	r.Text = text.String()
+	r.Thinking = thinking.String()
+}
+
+// EventReader decodes server-sent events without bufio.Scanner's line length limit
+type EventReader struct {
+	reader *bufio.Reader
+}
+
+// NewEventReader creates an EventReader reading from r
+func NewEventReader(r io.Reader) *EventReader {
+	return &EventReader{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Next returns the next event, or io.EOF once the stream is exhausted.
+// Events whose data is not valid JSON are skipped.
+func (r *EventReader) Next() (*StreamEvent, error) {
+	var data []byte
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		eof := err != nil
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) > 0 {
+			if field, value, ok := bytes.Cut(line, []byte(":")); ok && string(field) == "data" {
+				value = bytes.TrimPrefix(value, []byte(" "))
+				if data != nil {
+					data = append(data, '\n')
+				}
+				data = append(data, value...)
+			}
+		}
+
+		// 空行或流结束时分发已累积的事件
+		if len(line) == 0 || eof {
+			if data != nil {
+				var event StreamEvent
+				if jsonErr := json.Unmarshal(data, &event); jsonErr == nil {
+					return &event, nil
+				}
+				data = nil
+			}
+			if eof {
+				return nil, io.EOF
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readEvents 读取流中的全部事件
+func readEvents(t *testing.T, stream string) []*StreamEvent {
+	t.Helper()
+	reader := NewEventReader(strings.NewReader(stream))
+	events := []*StreamEvent{}
+	for {
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func TestEventReaderLongDataLine(t *testing.T) {
+	// 超过 bufio.Scanner 默认 64KB 上限的单行数据
+	text := strings.Repeat("x", 200*1024)
+	stream := fmt.Sprintf("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":%q}}\n\n", text)
+
+	events := readEvents(t, stream)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Type != EventContentBlockDelta || event.Delta == nil || event.Delta.Type != DeltaText {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if event.Delta.Text != text {
+		t.Fatalf("expected %d bytes of text, got %d", len(text), len(event.Delta.Text))
+	}
+}
+
+func TestEventReaderMixedStream(t *testing.T) {
+	stream := strings.Join([]string{
+		": keep-alive",
+		"",
+		"event: message_start",
+		`data: {"type":"message_start","message":{"id":"msg_1","uuid":"u-1","model":"claude-sonnet-4"}}`,
+		"",
+		"event: content_block_start",
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking"}}`,
+		"",
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+		"",
+		`data: {"type":"content_block_stop","index":0}`,
+		"",
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tool_1","name":"web_search"}}`,
+		"",
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+		"",
+		"data: not json",
+		"",
+		`data: {"type":"ping"}`,
+		"",
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+		"",
+		`data: {"type":"message_limit","message_limit":{"type":"within_limit","resetsAt":1700000000,"remaining":3}}`,
+		"",
+		// 跨多行的 data 字段以换行拼接，最后一个事件没有结尾空行
+		`data: {"type":"message_stop",`,
+		`data: "index":2}`,
+	}, "\r\n")
+
+	events := readEvents(t, stream)
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	expected := []string{
+		EventMessageStart,
+		EventContentBlockStart,
+		EventContentBlockDelta,
+		EventContentBlockStop,
+		EventContentBlockStart,
+		EventContentBlockDelta,
+		EventPing,
+		EventMessageDelta,
+		EventMessageLimit,
+		EventMessageStop,
+	}
+	if strings.Join(types, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected events %v, got %v", expected, types)
+	}
+
+	if message := events[0].Message; message == nil || message.ID != "msg_1" || message.UUID != "u-1" || message.Model != "claude-sonnet-4" {
+		t.Fatalf("unexpected message_start: %+v", events[0].Message)
+	}
+	if block := events[1].ContentBlock; block == nil || block.Type != BlockThinking {
+		t.Fatalf("unexpected thinking block: %+v", events[1].ContentBlock)
+	}
+	if delta := events[2].Delta; delta == nil || delta.Type != DeltaThinking || delta.Thinking != "hmm" {
+		t.Fatalf("unexpected thinking delta: %+v", events[2].Delta)
+	}
+	if events[3].Index != 0 {
+		t.Fatalf("expected content_block_stop for index 0, got %d", events[3].Index)
+	}
+	if block := events[4].ContentBlock; events[4].Index != 1 || block == nil || block.Type != BlockToolUse || block.ID != "tool_1" || block.Name != "web_search" {
+		t.Fatalf("unexpected tool_use block: %+v", events[4].ContentBlock)
+	}
+	if delta := events[5].Delta; delta == nil || delta.Type != DeltaInputJSON || delta.PartialJSON != `{"query":` {
+		t.Fatalf("unexpected input_json delta: %+v", events[5].Delta)
+	}
+	if delta := events[7].Delta; delta == nil || delta.StopReason != "end_turn" {
+		t.Fatalf("unexpected message_delta: %+v", events[7].Delta)
+	}
+	limit := events[8].MessageLimit
+	if limit == nil || limit.Type != "within_limit" || limit.ResetsAt != 1700000000 || limit.Remaining == nil || *limit.Remaining != 3 {
+		t.Fatalf("unexpected message_limit: %+v", limit)
+	}
+	if events[9].Index != 2 {
+		t.Fatalf("expected multi-line data to be joined, got index %d", events[9].Index)
+	}
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"claude2api/config"
+	"claude2api/logger"
+	"fmt"
+	"strings"
+)
+
+// AcceptLanguage builds the accept-language header of a locale, e.g. zh-CN,zh;q=0.9
+func AcceptLanguage(locale string) string {
+	if lang, _, ok := strings.Cut(locale, "-"); ok {
+		return locale + "," + lang + ";q=0.9"
+	}
+	return locale
+}
+
+// webTools maps tool names to the tool definitions claude.ai expects
+var webTools = map[string]map[string]interface{}{
+	"web_search": {
+		"type": "web_search_v0",
+		"name": "web_search",
+	},
+}
+
+// WebTools returns the tool definitions for the given names, unknown names are skipped
+func WebTools(names ...string) []map[string]interface{} {
+	tools := []map[string]interface{}{}
+	for _, name := range names {
+		tool, ok := webTools[name]
+		if !ok {
+			logger.Warn(fmt.Sprintf("Unknown web tool: %s", name))
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// CompletionPayload composes the body of a single completion request.
+// It owns all per-request state, so a Client can be shared between requests
+// without files or attachments leaking from one request into another.
+type CompletionPayload struct {
+	prompt      string
+	files       []string
+	attachments []map[string]interface{}
+	tools       []map[string]interface{}
+	style       Style
+	timezone    string
+	locale      string
+}
+
+// NewCompletionPayload creates a payload with the default tools enabled
+func NewCompletionPayload() *CompletionPayload {
+	return &CompletionPayload{
+		files:       []string{},
+		attachments: []map[string]interface{}{},
+		tools:       WebTools("web_search"),
+		style:       DefaultStyle,
+		timezone:    config.DefaultTimezone,
+		locale:      config.DefaultLocale,
+	}
+}
+
+// SetPrompt sets the prompt sent as the human turn
+func (p *CompletionPayload) SetPrompt(prompt string) *CompletionPayload {
+	p.prompt = prompt
+	return p
+}
+
+// AddFiles adds uploaded file UUIDs to the payload
+func (p *CompletionPayload) AddFiles(fileUUIDs ...string) *CompletionPayload {
+	p.files = append(p.files, fileUUIDs...)
+	return p
+}
+
+// AddAttachment adds a plain text attachment whose content is extracted inline
+func (p *CompletionPayload) AddAttachment(fileName string, content string) *CompletionPayload {
+	p.attachments = append(p.attachments, map[string]interface{}{
+		"file_name":         fileName,
+		"file_type":         "text/plain",
+		"file_size":         len(content),
+		"extracted_content": content,
+	})
+	return p
+}
+
+// SetTools replaces the tools enabled for the completion
+func (p *CompletionPayload) SetTools(tools []map[string]interface{}) *CompletionPayload {
+	p.tools = tools
+	return p
+}
+
+// SetStyle sets the personalized style of the reply
+func (p *CompletionPayload) SetStyle(style Style) *CompletionPayload {
+	p.style = style
+	return p
+}
+
+// SetTimezone sets the IANA timezone Claude uses for dates and times
+func (p *CompletionPayload) SetTimezone(timezone string) *CompletionPayload {
+	if timezone != "" {
+		p.timezone = timezone
+	}
+	return p
+}
+
+// SetLocale sets the locale sent as accept-language and in the request body
+func (p *CompletionPayload) SetLocale(locale string) *CompletionPayload {
+	if locale != "" {
+		p.locale = locale
+	}
+	return p
+}
+
+// Locale returns the locale of the payload
+func (p *CompletionPayload) Locale() string {
+	return p.locale
+}
+
+// Build returns a fresh request body, the payload itself is left untouched
+func (p *CompletionPayload) Build() map[string]interface{} {
+	files := make([]string, len(p.files))
+	copy(files, p.files)
+	attachments := make([]map[string]interface{}, len(p.attachments))
+	copy(attachments, p.attachments)
+	tools := make([]map[string]interface{}, len(p.tools))
+	copy(tools, p.tools)
+	return map[string]interface{}{
+		"prompt":              p.prompt,
+		"personalized_styles": []Style{p.style},
+		"tools":               tools,
+		"parent_message_uuid": "00000000-0000-4000-8000-000000000000",
+		"attachments":         attachments,
+		"files":               files,
+		"sync_sources":        []interface{}{},
+		"rendering_mode":      "messages",
+		"timezone":            p.timezone,
+		"locale":              p.locale,
+	}
+}
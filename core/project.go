@@ -0,0 +1,252 @@
+package core
+
+import (
+	"claude2api/config"
+	"claude2api/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Project is a claude.ai project
+type Project struct {
+	UUID        string `json:"uuid"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ArchivedAt  string `json:"archived_at"`
+}
+
+// ProjectDoc is a knowledge document of a project
+type ProjectDoc struct {
+	UUID     string `json:"uuid,omitempty"`
+	FileName string `json:"file_name"`
+	Content  string `json:"content"`
+}
+
+// ProjectSpec describes a project that should exist in every session's account
+type ProjectSpec struct {
+	Name         string
+	Description  string
+	Instructions string
+	Docs         []ProjectDoc
+}
+
+// ErrProjectNotFound is returned when a project no longer exists upstream
+var ErrProjectNotFound = errors.New("project not found")
+
+// projectCache 记录每个会话中已同步的项目，键为 sessionKey/orgID/项目名
+type projectCache struct {
+	mu       sync.Mutex
+	projects map[string]string
+	locks    map[string]*sync.Mutex
+}
+
+var projects = &projectCache{
+	projects: make(map[string]string),
+	locks:    make(map[string]*sync.Mutex),
+}
+
+func (p *projectCache) lock(key string) *sync.Mutex {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lock, ok := p.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.locks[key] = lock
+	}
+	return lock
+}
+
+func (p *projectCache) get(key string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	projectUUID, ok := p.projects[key]
+	return projectUUID, ok
+}
+
+func (p *projectCache) set(key string, projectUUID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.projects[key] = projectUUID
+}
+
+func (p *projectCache) remove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.projects, key)
+}
+
+// ForgetProject drops the cached UUID of the named project, so the next
+// EnsureProject looks it up again and re-syncs its instructions and docs
+func (c *Client) ForgetProject(name string) {
+	projects.remove(c.projectKey(name))
+}
+
+func (c *Client) projectKey(name string) string {
+	return c.SessionKey + "/" + c.orgID + "/" + name
+}
+
+// EnsureProject returns the UUID of the project described by spec in the
+// client's account. The first call per session finds the project by name or
+// creates it, then syncs its instructions and knowledge docs.
+func (c *Client) EnsureProject(spec ProjectSpec) (string, error) {
+	if c.orgID == "" {
+		return "", errors.New("organization ID not set")
+	}
+	key := c.projectKey(spec.Name)
+	if projectUUID, ok := projects.get(key); ok {
+		return projectUUID, nil
+	}
+	// 同一会话的同一项目只同步一次，避免并发请求重复创建
+	lock := projects.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if projectUUID, ok := projects.get(key); ok {
+		return projectUUID, nil
+	}
+
+	list, err := c.ListProjects()
+	if err != nil {
+		return "", err
+	}
+	var project *Project
+	for i := range list {
+		if list[i].Name == spec.Name && list[i].ArchivedAt == "" {
+			project = &list[i]
+			break
+		}
+	}
+	if project == nil {
+		if project, err = c.CreateProject(spec.Name, spec.Description); err != nil {
+			return "", err
+		}
+		logger.Info(fmt.Sprintf("Created project %s (%s) for session %s", spec.Name, project.UUID, config.MaskKey(c.SessionKey)))
+	}
+	if err := c.SetProjectInstructions(project.UUID, spec.Instructions); err != nil {
+		return "", err
+	}
+	if err := c.syncProjectDocs(project.UUID, spec.Docs); err != nil {
+		return "", err
+	}
+	projects.set(key, project.UUID)
+	return project.UUID, nil
+}
+
+// syncProjectDocs 上传缺少的文档，内容变化的文档先删除再上传
+func (c *Client) syncProjectDocs(projectUUID string, docs []ProjectDoc) error {
+	existing, err := c.ListProjectDocs(projectUUID)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]ProjectDoc, len(existing))
+	for _, doc := range existing {
+		current[doc.FileName] = doc
+	}
+	for _, doc := range docs {
+		if old, ok := current[doc.FileName]; ok {
+			if old.Content == doc.Content {
+				continue
+			}
+			if err := c.DeleteProjectDoc(projectUUID, old.UUID); err != nil {
+				return err
+			}
+		}
+		if _, err := c.UploadProjectDoc(projectUUID, doc.FileName, doc.Content); err != nil {
+			return err
+		}
+		logger.Info(fmt.Sprintf("Uploaded project doc %s to project %s", doc.FileName, projectUUID))
+	}
+	return nil
+}
+
+// ListProjects lists the projects of the organization
+func (c *Client) ListProjects() ([]Project, error) {
+	var list []Project
+	if err := c.projectRequest(http.MethodGet, "", nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreateProject creates a private project
+func (c *Client) CreateProject(name string, description string) (*Project, error) {
+	requestBody := map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"is_private":  true,
+	}
+	var project Project
+	if err := c.projectRequest(http.MethodPost, "", requestBody, &project); err != nil {
+		return nil, err
+	}
+	if project.UUID == "" {
+		return nil, errors.New("project UUID not found in response")
+	}
+	return &project, nil
+}
+
+// SetProjectInstructions sets the custom instructions of a project
+func (c *Client) SetProjectInstructions(projectUUID string, instructions string) error {
+	requestBody := map[string]interface{}{
+		"prompt_template": instructions,
+	}
+	return c.projectRequest(http.MethodPut, "/"+projectUUID, requestBody, nil)
+}
+
+// ListProjectDocs lists the knowledge docs of a project
+func (c *Client) ListProjectDocs(projectUUID string) ([]ProjectDoc, error) {
+	var docs []ProjectDoc
+	if err := c.projectRequest(http.MethodGet, "/"+projectUUID+"/docs", nil, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// UploadProjectDoc adds a text document to the project knowledge
+func (c *Client) UploadProjectDoc(projectUUID string, fileName string, content string) (*ProjectDoc, error) {
+	requestBody := map[string]interface{}{
+		"file_name": fileName,
+		"content":   content,
+	}
+	var doc ProjectDoc
+	if err := c.projectRequest(http.MethodPost, "/"+projectUUID+"/docs", requestBody, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// DeleteProjectDoc removes a document from the project knowledge
+func (c *Client) DeleteProjectDoc(projectUUID string, docUUID string) error {
+	return c.projectRequest(http.MethodDelete, "/"+projectUUID+"/docs/"+docUUID, nil, nil)
+}
+
+// projectRequest 发送项目相关请求，result 不为空时解析响应
+func (c *Client) projectRequest(method string, path string, body interface{}, result interface{}) error {
+	if c.orgID == "" {
+		return errors.New("organization ID not set")
+	}
+	url := fmt.Sprintf("https://claude.ai/api/organizations/%s/projects%s", c.orgID, path)
+	request := c.client.R().SetHeader("referer", "https://claude.ai/projects")
+	if body != nil {
+		request.SetBody(body)
+	}
+	resp, err := request.Send(method, url)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrProjectNotFound, path)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Bytes(), result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
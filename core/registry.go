@@ -0,0 +1,77 @@
+package core
+
+import (
+	"claude2api/config"
+	"claude2api/logger"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+// clientIdleTTL 为客户端的最长空闲时间，超过后从注册表中移除。
+// 镜像模式下每个请求的会话密钥都可能不同，不移除会无限增长
+const clientIdleTTL = 30 * time.Minute
+
+// registryEntry holds the pooled HTTP client of one session
+type registryEntry struct {
+	proxy    string
+	client   *req.Client
+	lastUsed time.Time
+}
+
+// clientRegistry keeps one HTTP client per session so that TLS handshakes
+// and connections are reused across requests. req.Client is safe for
+// concurrent use once configured, so entries are shared between goroutines.
+type clientRegistry struct {
+	mutex   sync.Mutex
+	entries map[string]*registryEntry
+	// lastEvict 为上次检查空闲客户端的时间，每分钟最多检查一次
+	lastEvict time.Time
+}
+
+var registry = &clientRegistry{
+	entries: make(map[string]*registryEntry),
+}
+
+// GetClient returns a Client for the session backed by its pooled HTTP client.
+// Per-request state lives in CompletionPayload, so the Client only carries the org ID.
+// The second return value reports whether the HTTP client was reused.
+func GetClient(sessionKey string, proxy string) (*Client, bool) {
+	client, reused := registry.get(sessionKey, proxy)
+	return newClientWith(sessionKey, client), reused
+}
+
+func (r *clientRegistry) get(sessionKey string, proxy string) (*req.Client, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(r.lastEvict) >= time.Minute {
+		r.evict(now)
+	}
+	if entry, ok := r.entries[sessionKey]; ok {
+		if entry.proxy == proxy {
+			entry.lastUsed = now
+			return entry.client, true
+		}
+		// 会话切换了代理，旧连接不再可用
+		logger.Info(fmt.Sprintf("Proxy of session %s changed, rebuilding client", config.MaskKey(sessionKey)))
+		entry.client.GetTransport().CloseIdleConnections()
+	}
+	client := newHTTPClient(sessionKey, proxy)
+	r.entries[sessionKey] = &registryEntry{proxy: proxy, client: client, lastUsed: now}
+	return client, false
+}
+
+// evict removes the clients idle for longer than clientIdleTTL, the caller must hold the mutex.
+// Requests still using an evicted client keep their connections, only idle ones are closed.
+func (r *clientRegistry) evict(now time.Time) {
+	r.lastEvict = now
+	for sessionKey, entry := range r.entries {
+		if now.Sub(entry.lastUsed) > clientIdleTTL {
+			entry.client.GetTransport().CloseIdleConnections()
+			delete(r.entries, sessionKey)
+		}
+	}
+}
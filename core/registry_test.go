@@ -0,0 +1,73 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/imroc/req/v3"
+)
+
+func TestClientRegistryReuseAndEvict(t *testing.T) {
+	r := &clientRegistry{entries: make(map[string]*registryEntry)}
+	first, reused := r.get("key", "")
+	if reused {
+		t.Fatal("expected a new client for an unknown session")
+	}
+	if client, reused := r.get("key", ""); !reused || client != first {
+		t.Fatal("expected the pooled client to be reused")
+	}
+	if client, reused := r.get("key", "http://127.0.0.1:2080"); reused || client == first {
+		t.Fatal("expected a new client after the proxy changed")
+	}
+	r.get("other", "")
+
+	now := time.Now()
+	r.entries["other"].lastUsed = now.Add(-clientIdleTTL - time.Second)
+	r.evict(now)
+	if _, ok := r.entries["other"]; ok {
+		t.Fatal("expected the idle client to be evicted")
+	}
+	if _, ok := r.entries["key"]; !ok {
+		t.Fatal("expected the recently used client to stay")
+	}
+}
+
+// benchmarkRequest 使用 client 向 TLS 测试服务器发送一次请求
+func benchmarkRequest(b *testing.B, client *req.Client, url string) {
+	resp, err := client.R().Get(url)
+	if err != nil {
+		b.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		b.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+// BenchmarkClient 比较复用注册表中的客户端与每次新建客户端的请求耗时，
+// 新建客户端每次都需要重新完成 TLS 握手
+func BenchmarkClient(b *testing.B) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	b.Run("pooled", func(b *testing.B) {
+		r := &clientRegistry{entries: make(map[string]*registryEntry)}
+		client, _ := r.get("key", "")
+		client.EnableInsecureSkipVerify()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			client, _ := r.get("key", "")
+			benchmarkRequest(b, client, server.URL)
+		}
+	})
+	b.Run("fresh", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			client := newHTTPClient("key", "").EnableInsecureSkipVerify()
+			benchmarkRequest(b, client, server.URL)
+			client.GetTransport().CloseIdleConnections()
+		}
+	})
+}
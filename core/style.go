@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Style is a personalized response style sent with personalized_styles
+type Style struct {
+	Type       string `json:"type"`
+	UUID       string `json:"uuid,omitempty"`
+	Key        string `json:"key"`
+	Name       string `json:"name"`
+	NameKey    string `json:"nameKey,omitempty"`
+	Prompt     string `json:"prompt"`
+	Summary    string `json:"summary"`
+	SummaryKey string `json:"summaryKey,omitempty"`
+	IsDefault  bool   `json:"isDefault"`
+}
+
+// DefaultStyle is claude.ai's Normal style
+var DefaultStyle = Style{
+	Type:       "default",
+	Key:        "Default",
+	Name:       "Normal",
+	NameKey:    "normal_style_name",
+	Prompt:     "Normal",
+	Summary:    "Default responses from Claude",
+	SummaryKey: "normal_style_summary",
+	IsDefault:  true,
+}
+
+// NewCustomStyle creates a custom style with its own prompt
+func NewCustomStyle(name string, prompt string, summary string) Style {
+	return Style{
+		Type:    "custom",
+		Key:     name,
+		Name:    name,
+		Prompt:  prompt,
+		Summary: summary,
+	}
+}
+
+// styleCacheTTL 账号的风格列表很少变化，缓存一段时间避免每次请求都查询
+const styleCacheTTL = time.Hour
+
+type styleCacheEntry struct {
+	styles    []Style
+	fetchedAt time.Time
+}
+
+var styleCache = struct {
+	mu      sync.Mutex
+	entries map[string]styleCacheEntry
+}{entries: make(map[string]styleCacheEntry)}
+
+// ListStyles lists the default and custom styles available to the account
+func (c *Client) ListStyles() ([]Style, error) {
+	if c.orgID == "" {
+		return nil, errors.New("organization ID not set")
+	}
+	url := fmt.Sprintf("https://claude.ai/api/organizations/%s/list_styles", c.orgID)
+	resp, err := c.client.R().
+		SetHeader("referer", "https://claude.ai/new").
+		Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var result struct {
+		DefaultStyles []Style `json:"defaultStyles"`
+		CustomStyles  []Style `json:"customStyles"`
+	}
+	if err := json.Unmarshal(resp.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	styles := append(result.DefaultStyles, result.CustomStyles...)
+	styleCache.mu.Lock()
+	styleCache.entries[c.SessionKey+"/"+c.orgID] = styleCacheEntry{styles: styles, fetchedAt: time.Now()}
+	styleCache.mu.Unlock()
+	return styles, nil
+}
+
+// FindStyle looks up an account style by key, name or UUID, case-insensitively
+func (c *Client) FindStyle(name string) (Style, error) {
+	styleCache.mu.Lock()
+	entry, ok := styleCache.entries[c.SessionKey+"/"+c.orgID]
+	styleCache.mu.Unlock()
+	styles := entry.styles
+	if !ok || time.Since(entry.fetchedAt) > styleCacheTTL {
+		var err error
+		if styles, err = c.ListStyles(); err != nil {
+			return Style{}, err
+		}
+	}
+	for _, style := range styles {
+		if strings.EqualFold(style.Key, name) || strings.EqualFold(style.Name, name) || (style.UUID != "" && style.UUID == name) {
+			return style, nil
+		}
+	}
+	return Style{}, fmt.Errorf("style %s not found", name)
+}
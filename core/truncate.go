@@ -0,0 +1,102 @@
+package core
+
+import (
+	"claude2api/utils"
+	"strings"
+)
+
+// ResponseOptions bounds the output forwarded to the client
+type ResponseOptions struct {
+	// MaxTokens 为估算的最大输出 token 数（包含思考内容），0 表示不限制
+	MaxTokens int
+	// Stop 为停止序列，命中后截断输出
+	Stop []string
+	// AbortUpstream 截断后通知 claude.ai 停止生成
+	AbortUpstream bool
+}
+
+// outputLimiter enforces stop sequences and the token budget on the streamed output
+type outputLimiter struct {
+	opts    ResponseOptions
+	tokens  utils.TokenCounter
+	pending string
+	// stopReason 非空表示输出已被截断
+	stopReason string
+}
+
+func newOutputLimiter(opts ResponseOptions) *outputLimiter {
+	stops := []string{}
+	for _, stop := range opts.Stop {
+		if stop != "" {
+			stops = append(stops, stop)
+		}
+	}
+	opts.Stop = stops
+	return &outputLimiter{opts: opts}
+}
+
+// text filters a text delta and returns the part that may be forwarded
+func (l *outputLimiter) text(text string) string {
+	if l.stopReason != "" {
+		return ""
+	}
+	buf := l.pending + text
+	l.pending = ""
+
+	// 命中停止序列时只输出其之前的内容
+	cut := -1
+	for _, stop := range l.opts.Stop {
+		if i := strings.Index(buf, stop); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut >= 0 {
+		out := l.fit(buf[:cut])
+		if l.stopReason == "" {
+			l.stopReason = "stop_sequence"
+		}
+		return out
+	}
+
+	// 暂存可能是停止序列开头的尾部，停止序列可能跨越多个分片
+	hold := 0
+	for _, stop := range l.opts.Stop {
+		for n := min(len(stop)-1, len(buf)); n > hold; n-- {
+			if strings.HasSuffix(buf, stop[:n]) {
+				hold = n
+				break
+			}
+		}
+	}
+	l.pending = buf[len(buf)-hold:]
+	return l.fit(buf[:len(buf)-hold])
+}
+
+// thinking filters a thinking delta, thinking counts towards the token budget
+func (l *outputLimiter) thinking(text string) string {
+	if l.stopReason != "" {
+		return ""
+	}
+	return l.fit(text)
+}
+
+// flush returns the text held back at the end of the stream
+func (l *outputLimiter) flush() string {
+	pending := l.pending
+	l.pending = ""
+	if l.stopReason != "" {
+		return ""
+	}
+	return l.fit(pending)
+}
+
+func (l *outputLimiter) fit(text string) string {
+	if l.opts.MaxTokens <= 0 {
+		return text
+	}
+	out, truncated := l.tokens.Fit(text, l.opts.MaxTokens)
+	if truncated {
+		l.stopReason = "max_tokens"
+	}
+	return out
+}
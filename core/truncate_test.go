@@ -0,0 +1,67 @@
+package core
+
+import (
+	"testing"
+)
+
+// limitChunks 依次过滤文本分片，返回转发的全部内容和截断原因
+func limitChunks(opts ResponseOptions, chunks ...string) (string, string) {
+	limiter := newOutputLimiter(opts)
+	out := ""
+	for _, chunk := range chunks {
+		out += limiter.text(chunk)
+	}
+	out += limiter.flush()
+	return out, limiter.stopReason
+}
+
+func TestOutputLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     ResponseOptions
+		chunks   []string
+		expected string
+		reason   string
+	}{
+		{"no limits", ResponseOptions{}, []string{"hello ", "world"}, "hello world", ""},
+		{"empty stop ignored", ResponseOptions{Stop: []string{""}}, []string{"hello"}, "hello", ""},
+		{"stop in one chunk", ResponseOptions{Stop: []string{"STOP"}}, []string{"hello STOP world"}, "hello ", "stop_sequence"},
+		{"stop across chunks", ResponseOptions{Stop: []string{"STOP"}}, []string{"hel", "lo ST", "OP more"}, "hello ", "stop_sequence"},
+		{"stop prefix released", ResponseOptions{Stop: []string{"STOP"}}, []string{"abc ST", "x"}, "abc STx", ""},
+		{"stop prefix flushed", ResponseOptions{Stop: []string{"STOP"}}, []string{"abc ST"}, "abc ST", ""},
+		{"earliest stop wins", ResponseOptions{Stop: []string{"world", "lo"}}, []string{"hello world"}, "hel", "stop_sequence"},
+		{"text after stop dropped", ResponseOptions{Stop: []string{"STOP"}}, []string{"a STOP", "b", "c"}, "a ", "stop_sequence"},
+		{"max tokens", ResponseOptions{MaxTokens: 2}, []string{"abcd", "efghijkl"}, "abcdefgh", "max_tokens"},
+		{"max tokens wide characters", ResponseOptions{MaxTokens: 2}, []string{"你好世界"}, "你好", "max_tokens"},
+		{"within max tokens", ResponseOptions{MaxTokens: 10}, []string{"abcd"}, "abcd", ""},
+		{"stop before max tokens", ResponseOptions{MaxTokens: 100, Stop: []string{"\n\n"}}, []string{"line\n", "\nnext"}, "line", "stop_sequence"},
+		{"max tokens before stop", ResponseOptions{MaxTokens: 1, Stop: []string{"STOP"}}, []string{"abcdefgh STOP"}, "abcd", "max_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, reason := limitChunks(tt.opts, tt.chunks...)
+			if out != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, out)
+			}
+			if reason != tt.reason {
+				t.Fatalf("expected stop reason %q, got %q", tt.reason, reason)
+			}
+		})
+	}
+}
+
+func TestOutputLimiterThinkingCountsTowardsBudget(t *testing.T) {
+	limiter := newOutputLimiter(ResponseOptions{MaxTokens: 2})
+	if thinking := limiter.thinking("abcd"); thinking != "abcd" {
+		t.Fatalf("expected thinking to pass, got %q", thinking)
+	}
+	if text := limiter.text("efghijkl"); text != "efgh" {
+		t.Fatalf("expected text to be cut by the remaining budget, got %q", text)
+	}
+	if limiter.stopReason != "max_tokens" {
+		t.Fatalf("expected max_tokens, got %q", limiter.stopReason)
+	}
+	if thinking := limiter.thinking("more"); thinking != "" {
+		t.Fatalf("expected nothing after truncation, got %q", thinking)
+	}
+}
@@ -8,6 +8,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/imroc/req/v3 v3.50.0
 	github.com/joho/godotenv v1.5.1
+	go.etcd.io/bbolt v1.3.11
 )
 
 require (
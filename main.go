@@ -2,18 +2,87 @@ package main
 
 import (
 	"claude2api/config"
+	"claude2api/logger"
 	"claude2api/router"
+	"claude2api/service"
+	"claude2api/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	r := gin.Default()
-	// Load configuration
+	// Open the persistent store and restore the saved session metadata
+	s, err := store.Open(config.ConfigInstance.StorePath)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to open store: %v", err))
+		os.Exit(1)
+	}
+	store.Default = s
+	service.RestoreSessions()
+	// 定时任务在收到退出信号后停止
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if err := service.StartSessionProbe(background); err != nil {
+		logger.Error(fmt.Sprintf("Refusing to start: %v", err))
+		os.Exit(1)
+	}
+
+	// Start the proxy health check, conversation sweeper and store pruner
+	service.StartProxyHealthCheck(background)
+	service.StartConversationSweeper(background)
+	service.StartStorePruner(background)
 
 	// Setup all routes
 	router.SetupRoutes(r)
 
-	// Run the server on 0.0.0.0:8080
-	r.Run(config.ConfigInstance.Address)
+	// Run the server on ADDRESS (default 0.0.0.0:8080)
+	server := &http.Server{
+		Addr:    config.ConfigInstance.Address,
+		Handler: r,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(fmt.Sprintf("Server error: %v", err))
+			os.Exit(1)
+		}
+	}()
+
+	// 收到退出信号后停止接收新请求，等待进行中的请求和对话删除完成
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	stop()
+	stopBackground()
+	timeout := config.ConfigInstance.ShutdownTimeout
+	logger.Info(fmt.Sprintf("Shutting down, waiting up to %s for in-flight requests", timeout))
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(drainCtx); err != nil {
+		// 超时后强制关闭连接，请求的上下文被取消，上游生成随之停止
+		logger.Warn(fmt.Sprintf("In-flight requests did not finish in time: %v", err))
+		server.Close()
+	}
+
+	cleanupCtx, cancelCleanup := context.WithTimeout(context.Background(), timeout)
+	defer cancelCleanup()
+	if err := service.WaitForCleanups(cleanupCtx); err != nil {
+		logger.Warn(fmt.Sprintf("Pending conversation cleanups did not finish in time: %v", err))
+	}
+	// 存储在所有使用它的任务结束后才关闭
+	if err := service.WaitForBackgroundTasks(cleanupCtx); err != nil {
+		logger.Warn(fmt.Sprintf("Background tasks did not finish in time: %v", err))
+	}
+	if err := store.Default.Close(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to close store: %v", err))
+	}
+	logger.Info("Server stopped")
 }
@@ -16,15 +16,29 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 		Key := c.GetHeader("Authorization")
+		// Anthropic 客户端使用 x-api-key，Gemini 客户端使用 x-goog-api-key 请求头或 key 查询参数
+		if Key == "" {
+			Key = c.GetHeader("x-api-key")
+		}
+		if Key == "" {
+			Key = c.GetHeader("x-goog-api-key")
+		}
+		if Key == "" {
+			Key = c.Query("key")
+		}
 		if Key != "" {
 			Key = strings.TrimPrefix(Key, "Bearer ")
-			if Key != config.ConfigInstance.APIKey {
+			apiKey, ok := config.ConfigInstance.GetAPIKey(Key)
+			if !ok {
 				c.JSON(401, gin.H{
 					"error": "Invalid API key",
 				})
 				c.Abort()
 				return
 			}
+			// 保存密钥定义，供后续处理读取密钥的默认参数
+			c.Set("APIKey", apiKey)
+			c.Set("Admin", config.ConfigInstance.IsAdminKey(Key))
 			c.Next()
 			return
 		}
@@ -34,3 +48,17 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Abort()
 	}
 }
+
+// AdminMiddleware only lets the admin key through, it must run after AuthMiddleware
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("Admin") {
+			c.JSON(403, gin.H{
+				"error": "Admin API key required",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
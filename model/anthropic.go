@@ -0,0 +1,76 @@
+package model
+
+import "errors"
+
+// AnthropicMessagesRequest 对应 Anthropic Messages API 的请求，system 可以是字符串或内容块数组
+type AnthropicMessagesRequest struct {
+	Model    string             `json:"model"`
+	System   interface{}        `json:"system,omitempty"`
+	Messages []AnthropicMessage `json:"messages"`
+}
+
+type AnthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// ToChatRequest 将 Anthropic 请求转换为 chat 请求
+func (r *AnthropicMessagesRequest) ToChatRequest() (*ChatCompletionRequest, error) {
+	if len(r.Messages) == 0 {
+		return nil, errors.New("no messages provided")
+	}
+	messages := []map[string]interface{}{}
+	if r.System != nil {
+		messages = append(messages, map[string]interface{}{
+			"role":    "system",
+			"content": anthropicContent(r.System),
+		})
+	}
+	for _, message := range r.Messages {
+		messages = append(messages, map[string]interface{}{
+			"role":    message.Role,
+			"content": anthropicContent(message.Content),
+		})
+	}
+	return &ChatCompletionRequest{
+		Model:    r.Model,
+		Messages: messages,
+	}, nil
+}
+
+// anthropicContent 将 Anthropic 内容块转换为 chat 格式，base64 图片转换为 data URL
+func anthropicContent(content interface{}) interface{} {
+	blocks, ok := content.([]interface{})
+	if !ok {
+		return content
+	}
+	converted := []interface{}{}
+	for _, block := range blocks {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch blockMap["type"] {
+		case "text":
+			converted = append(converted, map[string]interface{}{"type": "text", "text": blockMap["text"]})
+		case "image":
+			source, _ := blockMap["source"].(map[string]interface{})
+			var url string
+			switch source["type"] {
+			case "base64":
+				mediaType, _ := source["media_type"].(string)
+				data, _ := source["data"].(string)
+				url = "data:" + mediaType + ";base64," + data
+			case "url":
+				url, _ = source["url"].(string)
+			}
+			if url != "" {
+				converted = append(converted, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]interface{}{"url": url},
+				})
+			}
+		}
+	}
+	return converted
+}
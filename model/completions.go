@@ -0,0 +1,154 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CompletionRequest 对应旧版 /v1/completions 请求
+type CompletionRequest struct {
+	Model     string      `json:"model"`
+	Prompt    interface{} `json:"prompt"`
+	Stream    bool        `json:"stream"`
+	MaxTokens int         `json:"max_tokens,omitempty"`
+	Stop      interface{} `json:"stop,omitempty"`
+}
+
+// ToChatRequest 将 prompt 包装为单条用户消息，复用聊天请求的处理流程
+func (r *CompletionRequest) ToChatRequest() (*ChatCompletionRequest, error) {
+	var prompt string
+	switch v := r.Prompt.(type) {
+	case string:
+		prompt = v
+	case []interface{}:
+		if len(v) != 1 {
+			return nil, errors.New("exactly one prompt is supported")
+		}
+		text, ok := v[0].(string)
+		if !ok {
+			return nil, errors.New("prompt must be a string")
+		}
+		prompt = text
+	default:
+		return nil, errors.New("prompt must be a string")
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return nil, errors.New("no prompt provided")
+	}
+	return &ChatCompletionRequest{
+		Model: r.Model,
+		Messages: []map[string]interface{}{
+			{"role": "user", "content": prompt},
+		},
+		Stream:    r.Stream,
+		MaxTokens: r.MaxTokens,
+		Stop:      r.Stop,
+	}, nil
+}
+
+// TextCompletionResponse 定义 text_completion 响应结构，流式与非流式共用
+type TextCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []TextCompletionChoice `json:"choices"`
+	Usage   *Usage                 `json:"usage,omitempty"`
+}
+
+type TextCompletionChoice struct {
+	Text         string      `json:"text"`
+	Index        int         `json:"index"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason interface{} `json:"finish_reason"`
+}
+
+// TextCompletionWriter 将 Claude 的输出渲染为 text_completion 格式，思考内容不输出
+type TextCompletionWriter struct {
+	gc      *gin.Context
+	stream  bool
+	model   string
+	id      string
+	created int64
+	content strings.Builder
+}
+
+// NewTextCompletionWriter creates a writer for /v1/completions responses
+func NewTextCompletionWriter(gc *gin.Context, stream bool, model string) *TextCompletionWriter {
+	return &TextCompletionWriter{
+		gc:      gc,
+		stream:  stream,
+		model:   model,
+		id:      "cmpl-" + uuid.New().String(),
+		created: time.Now().Unix(),
+	}
+}
+
+func (w *TextCompletionWriter) Start() error {
+	if w.stream {
+		StartEventStream(w.gc)
+	}
+	return nil
+}
+
+func (w *TextCompletionWriter) WriteText(text string) error {
+	w.content.WriteString(text)
+	if !w.stream {
+		return nil
+	}
+	return WriteEventData(w.gc, w.response(text, nil))
+}
+
+func (w *TextCompletionWriter) WriteThinking(text string) error {
+	return nil
+}
+
+func (w *TextCompletionWriter) WriteAnnotation(annotation Annotation) error {
+	return nil
+}
+
+func (w *TextCompletionWriter) WriteError(message string) error {
+	if w.stream {
+		return WriteEventData(w.gc, w.response(message, nil))
+	}
+	w.gc.JSON(200, w.response(message, "stop"))
+	return nil
+}
+
+func (w *TextCompletionWriter) Finish(info CompletionInfo) error {
+	if info.FinishReason == "" {
+		info.FinishReason = "stop"
+	}
+	if !w.stream {
+		resp := w.response(w.content.String(), info.FinishReason)
+		resp.Usage = &Usage{}
+		w.gc.JSON(200, resp)
+		return nil
+	}
+	if err := WriteEventData(w.gc, w.response("", info.FinishReason)); err != nil {
+		return err
+	}
+	EndEventStream(w.gc)
+	return nil
+}
+
+func (w *TextCompletionWriter) response(text string, finishReason interface{}) *TextCompletionResponse {
+	return &TextCompletionResponse{
+		ID:      w.id,
+		Object:  "text_completion",
+		Created: w.created,
+		Model:   w.model,
+		Choices: []TextCompletionChoice{
+			{
+				Text:         text,
+				Index:        0,
+				Logprobs:     nil,
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
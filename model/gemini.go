@@ -0,0 +1,378 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GeminiRequest 对应 Gemini 的 generateContent / streamGenerateContent 请求
+type GeminiRequest struct {
+	Contents          []GeminiContent          `json:"contents"`
+	SystemInstruction *GeminiContent           `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig  `json:"generationConfig,omitempty"`
+	Tools             []map[string]interface{} `json:"tools,omitempty"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text       string            `json:"text"`
+	Thought    bool              `json:"thought,omitempty"`
+	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+}
+
+type GeminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type GeminiGenerationConfig struct {
+	MaxOutputTokens  int                    `json:"maxOutputTokens,omitempty"`
+	StopSequences    []string               `json:"stopSequences,omitempty"`
+	ResponseMimeType string                 `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]interface{} `json:"responseSchema,omitempty"`
+	ThinkingConfig   *GeminiThinkingConfig  `json:"thinkingConfig,omitempty"`
+}
+
+type GeminiThinkingConfig struct {
+	// ThinkingBudget 为 0 时关闭思考，-1 或正数时开启
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+// ParseGeminiRequest 解析 Gemini 请求，REST 接口同时接受 camelCase 和 snake_case 字段名
+func ParseGeminiRequest(body []byte) (*GeminiRequest, error) {
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	normalized, err := json.Marshal(camelCaseKeys(raw))
+	if err != nil {
+		return nil, err
+	}
+	var req GeminiRequest
+	if err := json.Unmarshal(normalized, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// camelCaseKeys 递归转换字段名，responseSchema 中是用户定义的属性名，保持不变
+func camelCaseKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			key = snakeToCamel(key)
+			if key == "responseSchema" {
+				converted[key] = item
+				continue
+			}
+			converted[key] = camelCaseKeys(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = camelCaseKeys(item)
+		}
+		return v
+	}
+	return value
+}
+
+func snakeToCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// IncludeThoughts 判断是否需要在回复中返回思考内容
+func (r *GeminiRequest) IncludeThoughts() bool {
+	return r.GenerationConfig != nil && r.GenerationConfig.ThinkingConfig != nil && r.GenerationConfig.ThinkingConfig.IncludeThoughts
+}
+
+// ToChatRequest 将 Gemini 请求转换为 chat 请求，model 来自请求路径
+func (r *GeminiRequest) ToChatRequest(model string, stream bool) (*ChatCompletionRequest, error) {
+	messages := []map[string]interface{}{}
+	if r.SystemInstruction != nil {
+		messages = append(messages, map[string]interface{}{
+			"role":    "system",
+			"content": geminiPartsContent(r.SystemInstruction.Parts),
+		})
+	}
+	for _, content := range r.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":    role,
+			"content": geminiPartsContent(content.Parts),
+		})
+	}
+	if len(r.Contents) == 0 {
+		return nil, errors.New("no contents provided")
+	}
+
+	chatReq := &ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   stream,
+	}
+	for _, tool := range r.Tools {
+		if _, ok := tool["googleSearch"]; ok {
+			webSearch := true
+			chatReq.WebSearch = &webSearch
+		}
+		if _, ok := tool["googleSearchRetrieval"]; ok {
+			webSearch := true
+			chatReq.WebSearch = &webSearch
+		}
+	}
+	if config := r.GenerationConfig; config != nil {
+		chatReq.MaxTokens = config.MaxOutputTokens
+		if len(config.StopSequences) > 0 {
+			stops := []interface{}{}
+			for _, stop := range config.StopSequences {
+				stops = append(stops, stop)
+			}
+			chatReq.Stop = stops
+		}
+		if config.ResponseMimeType == "application/json" {
+			chatReq.ResponseFormat = &ResponseFormat{Type: "json_object"}
+			if config.ResponseSchema != nil {
+				chatReq.ResponseFormat = &ResponseFormat{
+					Type:       "json_schema",
+					JSONSchema: &JSONSchemaFormat{Name: "response", Schema: lowerSchemaTypes(config.ResponseSchema)},
+				}
+			}
+		}
+		if thinking := config.ThinkingConfig; thinking != nil {
+			if thinking.ThinkingBudget != nil {
+				chatReq.Thinking = &ThinkingConfig{Type: "enabled"}
+				if *thinking.ThinkingBudget == 0 {
+					chatReq.Thinking.Type = "disabled"
+				}
+			} else if thinking.IncludeThoughts {
+				chatReq.Thinking = &ThinkingConfig{Type: "enabled"}
+			}
+		}
+	}
+	return chatReq, nil
+}
+
+// geminiPartsContent 将 parts 转换为 chat 格式的内容，inlineData 转换为 data URL 图片
+func geminiPartsContent(parts []GeminiPart) []interface{} {
+	content := []interface{}{}
+	for _, part := range parts {
+		if part.InlineData != nil {
+			content = append(content, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": "data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data},
+			})
+			continue
+		}
+		if part.Text != "" && !part.Thought {
+			content = append(content, map[string]interface{}{"type": "text", "text": part.Text})
+		}
+	}
+	return content
+}
+
+// lowerSchemaTypes 将 Gemini schema 中大写的 type（如 OBJECT）转换为 JSON Schema 的小写形式
+func lowerSchemaTypes(schema map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		switch v := value.(type) {
+		case string:
+			if key == "type" {
+				value = strings.ToLower(v)
+			}
+		case map[string]interface{}:
+			if key == "properties" {
+				properties := make(map[string]interface{}, len(v))
+				for name, property := range v {
+					if propertySchema, ok := property.(map[string]interface{}); ok {
+						properties[name] = lowerSchemaTypes(propertySchema)
+					}
+				}
+				value = properties
+			} else {
+				value = lowerSchemaTypes(v)
+			}
+		case []interface{}:
+			items := make([]interface{}, len(v))
+			for i, item := range v {
+				if itemSchema, ok := item.(map[string]interface{}); ok {
+					items[i] = lowerSchemaTypes(itemSchema)
+				} else {
+					items[i] = item
+				}
+			}
+			value = items
+		}
+		converted[key] = value
+	}
+	return converted
+}
+
+// GeminiResponse 为 generateContent 的响应，流式输出时每个分片也是该结构
+type GeminiResponse struct {
+	Candidates   []GeminiCandidate `json:"candidates"`
+	ModelVersion string            `json:"modelVersion"`
+}
+
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+// GeminiWriter 将 Claude 的输出渲染为 Gemini 格式。流式输出时 sse 为 true 使用 SSE，
+// 否则与 Gemini 一致，逐步输出一个 JSON 数组
+type GeminiWriter struct {
+	gc              *gin.Context
+	stream          bool
+	sse             bool
+	model           string
+	includeThoughts bool
+	chunks          int
+	content         strings.Builder
+	thinking        strings.Builder
+}
+
+// NewGeminiWriter creates a writer for generateContent and streamGenerateContent responses
+func NewGeminiWriter(gc *gin.Context, stream bool, sse bool, model string, includeThoughts bool) *GeminiWriter {
+	return &GeminiWriter{
+		gc:              gc,
+		stream:          stream,
+		sse:             sse,
+		model:           model,
+		includeThoughts: includeThoughts,
+	}
+}
+
+func (w *GeminiWriter) Start() error {
+	if !w.stream {
+		return nil
+	}
+	if w.sse {
+		StartEventStream(w.gc)
+		return nil
+	}
+	w.gc.Writer.Header().Set("Content-Type", "application/json")
+	w.gc.Writer.WriteHeader(http.StatusOK)
+	w.gc.Writer.Write([]byte("["))
+	w.gc.Writer.Flush()
+	return nil
+}
+
+func (w *GeminiWriter) WriteText(text string) error {
+	w.content.WriteString(text)
+	if !w.stream {
+		return nil
+	}
+	return w.writeChunk(w.response([]GeminiPart{{Text: text}}, ""))
+}
+
+func (w *GeminiWriter) WriteThinking(text string) error {
+	if !w.includeThoughts {
+		return nil
+	}
+	w.thinking.WriteString(text)
+	if !w.stream {
+		return nil
+	}
+	return w.writeChunk(w.response([]GeminiPart{{Text: text, Thought: true}}, ""))
+}
+
+func (w *GeminiWriter) WriteAnnotation(annotation Annotation) error {
+	return nil
+}
+
+func (w *GeminiWriter) WriteError(message string) error {
+	geminiErr := gin.H{"error": gin.H{"code": http.StatusInternalServerError, "message": message, "status": "INTERNAL"}}
+	if !w.stream {
+		w.gc.JSON(http.StatusInternalServerError, geminiErr)
+		return nil
+	}
+	if err := w.writeChunk(geminiErr); err != nil {
+		return err
+	}
+	w.end()
+	return nil
+}
+
+func (w *GeminiWriter) Finish(info CompletionInfo) error {
+	finishReason := "STOP"
+	if info.FinishReason == "length" {
+		finishReason = "MAX_TOKENS"
+	}
+	if !w.stream {
+		parts := []GeminiPart{}
+		if w.thinking.Len() > 0 {
+			parts = append(parts, GeminiPart{Text: w.thinking.String(), Thought: true})
+		}
+		parts = append(parts, GeminiPart{Text: w.content.String()})
+		w.gc.JSON(http.StatusOK, w.response(parts, finishReason))
+		return nil
+	}
+	if err := w.writeChunk(w.response([]GeminiPart{{Text: ""}}, finishReason)); err != nil {
+		return err
+	}
+	w.end()
+	return nil
+}
+
+func (w *GeminiWriter) writeChunk(data interface{}) error {
+	if w.sse {
+		return WriteEventData(w.gc, data)
+	}
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if w.chunks > 0 {
+		w.gc.Writer.Write([]byte(",\r\n"))
+	}
+	w.chunks++
+	w.gc.Writer.Write(jsonBytes)
+	w.gc.Writer.Flush()
+	return nil
+}
+
+// end 结束 JSON 数组，SSE 模式不需要结束标志
+func (w *GeminiWriter) end() {
+	if w.sse {
+		return
+	}
+	w.gc.Writer.Write([]byte("]"))
+	w.gc.Writer.Flush()
+}
+
+func (w *GeminiWriter) response(parts []GeminiPart, finishReason string) *GeminiResponse {
+	return &GeminiResponse{
+		Candidates: []GeminiCandidate{
+			{
+				Content:      GeminiContent{Role: "model", Parts: parts},
+				FinishReason: finishReason,
+				Index:        0,
+			},
+		},
+		ModelVersion: w.model,
+	}
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestGeminiRequestToChatRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		stream   bool
+		expected string
+	}{
+		{
+			"roles and system instruction",
+			`{"systemInstruction":{"parts":[{"text":"be brief"}]},"contents":[
+				{"role":"user","parts":[{"text":"hi"}]},
+				{"role":"model","parts":[{"text":"thinking","thought":true},{"text":"hello"}]},
+				{"parts":[{"text":"bye"}]}
+			]}`,
+			true,
+			`{"model":"gemini-2.5-pro","stream":true,"messages":[
+				{"role":"system","content":[{"type":"text","text":"be brief"}]},
+				{"role":"user","content":[{"type":"text","text":"hi"}]},
+				{"role":"assistant","content":[{"type":"text","text":"hello"}]},
+				{"role":"user","content":[{"type":"text","text":"bye"}]}
+			]}`,
+		},
+		{
+			"snake case fields and inline data",
+			`{"system_instruction":{"parts":[{"text":"be brief"}]},"contents":[{"role":"user","parts":[
+				{"text":"what is this"},
+				{"inline_data":{"mime_type":"image/png","data":"iVBORw0KGgo"}}
+			]}],"generation_config":{"max_output_tokens":32,"stop_sequences":["END"]}}`,
+			false,
+			`{"model":"gemini-2.5-pro","stream":false,"max_tokens":32,"stop":["END"],"messages":[
+				{"role":"system","content":[{"type":"text","text":"be brief"}]},
+				{"role":"user","content":[
+					{"type":"text","text":"what is this"},
+					{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo"}}
+				]}
+			]}`,
+		},
+		{
+			"google search",
+			`{"contents":[{"parts":[{"text":"news"}]}],"tools":[{"googleSearch":{}}]}`,
+			false,
+			`{"model":"gemini-2.5-pro","stream":false,"web_search":true,"messages":[{"role":"user","content":[{"type":"text","text":"news"}]}]}`,
+		},
+		{
+			"json mode",
+			`{"contents":[{"parts":[{"text":"hi"}]}],"generationConfig":{"responseMimeType":"application/json"}}`,
+			false,
+			`{"model":"gemini-2.5-pro","stream":false,"response_format":{"type":"json_object"},"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`,
+		},
+		{
+			"response schema keeps property names",
+			`{"contents":[{"parts":[{"text":"hi"}]}],"generation_config":{"response_mime_type":"application/json","response_schema":{
+				"type":"OBJECT","properties":{"first_name":{"type":"STRING"},"tags":{"type":"ARRAY","items":{"type":"STRING"}}},"required":["first_name"]
+			}}}`,
+			false,
+			`{"model":"gemini-2.5-pro","stream":false,"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}],
+				"response_format":{"type":"json_schema","json_schema":{"name":"response","schema":{
+					"type":"object","properties":{"first_name":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}},"required":["first_name"]
+				}}}}`,
+		},
+		{
+			"thinking budget zero disables thinking",
+			`{"contents":[{"parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingBudget":0}}}`,
+			false,
+			`{"model":"gemini-2.5-pro","stream":false,"thinking":{"type":"disabled"},"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`,
+		},
+		{
+			"dynamic thinking budget",
+			`{"contents":[{"parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingBudget":-1}}}`,
+			false,
+			`{"model":"gemini-2.5-pro","stream":false,"thinking":{"type":"enabled"},"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`,
+		},
+		{
+			"include thoughts enables thinking",
+			`{"contents":[{"parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"includeThoughts":true}}}`,
+			false,
+			`{"model":"gemini-2.5-pro","stream":false,"thinking":{"type":"enabled"},"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := ParseGeminiRequest([]byte(tt.request))
+			if err != nil {
+				t.Fatalf("invalid request: %v", err)
+			}
+			chatReq, err := request.ToChatRequest("gemini-2.5-pro", tt.stream)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertChatRequest(t, chatReq, tt.expected)
+		})
+	}
+}
+
+func TestGeminiRequestWithoutContents(t *testing.T) {
+	request, err := ParseGeminiRequest([]byte(`{"systemInstruction":{"parts":[{"text":"be brief"}]}}`))
+	if err != nil {
+		t.Fatalf("invalid request: %v", err)
+	}
+	if _, err := request.ToChatRequest("gemini-2.5-pro", false); err == nil {
+		t.Fatal("expected an error without contents")
+	}
+}
+
+func TestGeminiIncludeThoughts(t *testing.T) {
+	request, err := ParseGeminiRequest([]byte(`{"contents":[],"generation_config":{"thinking_config":{"include_thoughts":true}}}`))
+	if err != nil {
+		t.Fatalf("invalid request: %v", err)
+	}
+	if !request.IncludeThoughts() {
+		t.Fatal("expected include_thoughts to be parsed")
+	}
+	if (&GeminiRequest{}).IncludeThoughts() {
+		t.Fatal("expected no thoughts without a thinking config")
+	}
+}
@@ -0,0 +1,257 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OllamaMessage 为 Ollama 格式的消息，images 为不带前缀的 base64 图片
+type OllamaMessage struct {
+	Role     string   `json:"role"`
+	Content  string   `json:"content"`
+	Thinking string   `json:"thinking,omitempty"`
+	Images   []string `json:"images,omitempty"`
+}
+
+// OllamaOptions 只解析会影响输出的参数，其它采样参数忽略
+type OllamaOptions struct {
+	NumPredict int      `json:"num_predict,omitempty"`
+	Stop       []string `json:"stop,omitempty"`
+}
+
+// OllamaChatRequest 对应 Ollama 的 /api/chat 请求
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	// Stream 默认为 true
+	Stream  *bool          `json:"stream,omitempty"`
+	Format  interface{}    `json:"format,omitempty"`
+	Options *OllamaOptions `json:"options,omitempty"`
+	// Think 可以是布尔值或 low / medium / high
+	Think interface{} `json:"think,omitempty"`
+}
+
+// OllamaGenerateRequest 对应 Ollama 的 /api/generate 请求
+type OllamaGenerateRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	System  string         `json:"system,omitempty"`
+	Images  []string       `json:"images,omitempty"`
+	Stream  *bool          `json:"stream,omitempty"`
+	Format  interface{}    `json:"format,omitempty"`
+	Options *OllamaOptions `json:"options,omitempty"`
+	Think   interface{}    `json:"think,omitempty"`
+}
+
+// ToChatRequest 将 Ollama chat 请求转换为 chat 请求
+func (r *OllamaChatRequest) ToChatRequest() (*ChatCompletionRequest, error) {
+	if len(r.Messages) == 0 {
+		return nil, errors.New("no messages provided")
+	}
+	messages := []map[string]interface{}{}
+	for _, message := range r.Messages {
+		messages = append(messages, map[string]interface{}{
+			"role":    message.Role,
+			"content": ollamaContent(message.Content, message.Images),
+		})
+	}
+	return newOllamaChatRequest(r.Model, messages, r.Stream, r.Format, r.Options, r.Think), nil
+}
+
+// ToChatRequest 将 Ollama generate 请求转换为单轮 chat 请求
+func (r *OllamaGenerateRequest) ToChatRequest() (*ChatCompletionRequest, error) {
+	if strings.TrimSpace(r.Prompt) == "" {
+		return nil, errors.New("no prompt provided")
+	}
+	messages := []map[string]interface{}{}
+	if r.System != "" {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": r.System})
+	}
+	messages = append(messages, map[string]interface{}{
+		"role":    "user",
+		"content": ollamaContent(r.Prompt, r.Images),
+	})
+	return newOllamaChatRequest(r.Model, messages, r.Stream, r.Format, r.Options, r.Think), nil
+}
+
+func newOllamaChatRequest(model string, messages []map[string]interface{}, stream *bool, format interface{}, options *OllamaOptions, think interface{}) *ChatCompletionRequest {
+	chatReq := &ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   stream == nil || *stream,
+	}
+	if options != nil {
+		chatReq.MaxTokens = options.NumPredict
+		if len(options.Stop) > 0 {
+			stops := []interface{}{}
+			for _, stop := range options.Stop {
+				stops = append(stops, stop)
+			}
+			chatReq.Stop = stops
+		}
+	}
+	switch v := think.(type) {
+	case bool:
+		if v {
+			chatReq.Thinking = &ThinkingConfig{Type: "enabled"}
+		} else {
+			chatReq.Thinking = &ThinkingConfig{Type: "disabled"}
+		}
+	case string:
+		chatReq.ReasoningEffort = v
+	}
+	// format 为 "json" 或 JSON schema
+	switch v := format.(type) {
+	case string:
+		if v == "json" {
+			chatReq.ResponseFormat = &ResponseFormat{Type: "json_object"}
+		}
+	case map[string]interface{}:
+		chatReq.ResponseFormat = &ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &JSONSchemaFormat{Name: "format", Schema: v},
+		}
+	}
+	return chatReq
+}
+
+// ollamaContent 将文本和图片转换为 chat 格式的内容
+func ollamaContent(text string, images []string) interface{} {
+	if len(images) == 0 {
+		return text
+	}
+	parts := []interface{}{map[string]interface{}{"type": "text", "text": text}}
+	for _, image := range images {
+		parts = append(parts, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": imageDataURL(image)},
+		})
+	}
+	return parts
+}
+
+// imageDataURL 为不带前缀的 base64 图片补上 data URL 前缀，类型根据内容识别
+func imageDataURL(data string) string {
+	if strings.HasPrefix(data, "data:") {
+		return data
+	}
+	head := data
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	mimeType := "image/png"
+	if decoded, err := base64.StdEncoding.DecodeString(head[:len(head)/4*4]); err == nil {
+		if detected := http.DetectContentType(decoded); strings.HasPrefix(detected, "image/") {
+			mimeType = detected
+		}
+	}
+	return "data:" + mimeType + ";base64," + data
+}
+
+// OllamaResponse 为 /api/chat 和 /api/generate 的响应行，chat 使用 Message，generate 使用 Response
+type OllamaResponse struct {
+	Model         string         `json:"model"`
+	CreatedAt     string         `json:"created_at"`
+	Message       *OllamaMessage `json:"message,omitempty"`
+	Response      *string        `json:"response,omitempty"`
+	Thinking      string         `json:"thinking,omitempty"`
+	Done          bool           `json:"done"`
+	DoneReason    string         `json:"done_reason,omitempty"`
+	TotalDuration int64          `json:"total_duration,omitempty"`
+}
+
+// OllamaWriter 将 Claude 的输出渲染为 Ollama 的 NDJSON 格式
+type OllamaWriter struct {
+	gc       *gin.Context
+	stream   bool
+	model    string
+	generate bool
+	start    time.Time
+	content  strings.Builder
+	thinking strings.Builder
+}
+
+// NewOllamaWriter creates a writer for /api/chat, or /api/generate when generate is set
+func NewOllamaWriter(gc *gin.Context, stream bool, model string, generate bool) *OllamaWriter {
+	return &OllamaWriter{
+		gc:       gc,
+		stream:   stream,
+		model:    model,
+		generate: generate,
+		start:    time.Now(),
+	}
+}
+
+func (w *OllamaWriter) Start() error {
+	if w.stream {
+		StartNDJSONStream(w.gc)
+	}
+	return nil
+}
+
+func (w *OllamaWriter) WriteText(text string) error {
+	w.content.WriteString(text)
+	if !w.stream {
+		return nil
+	}
+	return WriteNDJSON(w.gc, w.response(text, ""))
+}
+
+func (w *OllamaWriter) WriteThinking(text string) error {
+	w.thinking.WriteString(text)
+	if !w.stream {
+		return nil
+	}
+	return WriteNDJSON(w.gc, w.response("", text))
+}
+
+func (w *OllamaWriter) WriteAnnotation(annotation Annotation) error {
+	return nil
+}
+
+func (w *OllamaWriter) WriteError(message string) error {
+	if w.stream {
+		return WriteNDJSON(w.gc, gin.H{"error": message})
+	}
+	w.gc.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	return nil
+}
+
+func (w *OllamaWriter) Finish(info CompletionInfo) error {
+	var resp *OllamaResponse
+	if w.stream {
+		resp = w.response("", "")
+	} else {
+		resp = w.response(w.content.String(), w.thinking.String())
+	}
+	resp.Done = true
+	resp.DoneReason = "stop"
+	if info.FinishReason == "length" {
+		resp.DoneReason = "length"
+	}
+	resp.TotalDuration = time.Since(w.start).Nanoseconds()
+	if !w.stream {
+		w.gc.JSON(http.StatusOK, resp)
+		return nil
+	}
+	return WriteNDJSON(w.gc, resp)
+}
+
+func (w *OllamaWriter) response(text string, thinking string) *OllamaResponse {
+	resp := &OllamaResponse{
+		Model:     w.model,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if w.generate {
+		resp.Response = &text
+		resp.Thinking = thinking
+	} else {
+		resp.Message = &OllamaMessage{Role: "assistant", Content: text, Thinking: thinking}
+	}
+	return resp
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// assertChatRequest 比较转换后的 chat 请求与期望的 JSON
+func assertChatRequest(t *testing.T, chatReq *ChatCompletionRequest, expected string) {
+	t.Helper()
+	data, err := json.Marshal(chatReq)
+	if err != nil {
+		t.Fatalf("failed to marshal chat request: %v", err)
+	}
+	var got, want interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal chat request: %v", err)
+	}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", expected, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected chat request\n got: %s\nwant: %s", data, expected)
+	}
+}
+
+func TestOllamaChatRequestToChatRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		expected string
+	}{
+		{
+			"defaults to streaming",
+			`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}]}`,
+			`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}],"stream":true}`,
+		},
+		{
+			"options and think",
+			`{"model":"m","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}],"stream":false,"options":{"num_predict":64,"stop":["\n"]},"think":true}`,
+			`{"model":"m","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}],"stream":false,"max_tokens":64,"stop":["\n"],"thinking":{"type":"enabled"}}`,
+		},
+		{
+			"think disabled",
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"think":false}`,
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"stream":true,"thinking":{"type":"disabled"}}`,
+		},
+		{
+			"think effort",
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"think":"high"}`,
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"stream":true,"reasoning_effort":"high"}`,
+		},
+		{
+			"json format",
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"format":"json"}`,
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"stream":true,"response_format":{"type":"json_object"}}`,
+		},
+		{
+			"schema format",
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"format":{"type":"object"}}`,
+			`{"model":"m","messages":[{"role":"user","content":"hi"}],"stream":true,"response_format":{"type":"json_schema","json_schema":{"name":"format","schema":{"type":"object"}}}}`,
+		},
+		{
+			"images",
+			`{"model":"m","messages":[{"role":"user","content":"what is this","images":["/9j/4AAQSkZJRgABAQ","data:image/gif;base64,R0lGOD"]}]}`,
+			`{"model":"m","messages":[{"role":"user","content":[
+				{"type":"text","text":"what is this"},
+				{"type":"image_url","image_url":{"url":"data:image/jpeg;base64,/9j/4AAQSkZJRgABAQ"}},
+				{"type":"image_url","image_url":{"url":"data:image/gif;base64,R0lGOD"}}
+			]}],"stream":true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request OllamaChatRequest
+			if err := json.Unmarshal([]byte(tt.request), &request); err != nil {
+				t.Fatalf("invalid request: %v", err)
+			}
+			chatReq, err := request.ToChatRequest()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertChatRequest(t, chatReq, tt.expected)
+		})
+	}
+}
+
+func TestOllamaChatRequestWithoutMessages(t *testing.T) {
+	request := OllamaChatRequest{Model: "m"}
+	if _, err := request.ToChatRequest(); err == nil {
+		t.Fatal("expected an error without messages")
+	}
+}
+
+func TestOllamaGenerateRequestToChatRequest(t *testing.T) {
+	var request OllamaGenerateRequest
+	body := `{"model":"m","system":"be brief","prompt":"hi","images":["iVBORw0KGgoAAAANSUhEUg"],"stream":false}`
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatalf("invalid request: %v", err)
+	}
+	chatReq, err := request.ToChatRequest()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertChatRequest(t, chatReq, `{"model":"m","messages":[
+		{"role":"system","content":"be brief"},
+		{"role":"user","content":[
+			{"type":"text","text":"hi"},
+			{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgoAAAANSUhEUg"}}
+		]}
+	],"stream":false}`)
+
+	blank := OllamaGenerateRequest{Model: "m", Prompt: "  "}
+	if _, err := blank.ToChatRequest(); err == nil {
+		t.Fatal("expected an error for a blank prompt")
+	}
+}
@@ -1,29 +1,148 @@
 package model
 
 import (
-	"claude2api/logger"
-	"encoding/json"
-	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type ChatCompletionRequest struct {
-	Model    string                   `json:"model"`
-	Messages []map[string]interface{} `json:"messages"`
-	Stream   bool                     `json:"stream"`
-	Tools    []map[string]interface{} `json:"tools,omitempty"`
+	Model            string                   `json:"model"`
+	Messages         []map[string]interface{} `json:"messages"`
+	Stream           bool                     `json:"stream"`
+	Tools            []map[string]interface{} `json:"tools,omitempty"`
+	WebSearch        *bool                    `json:"web_search,omitempty"`
+	WebSearchOptions map[string]interface{}   `json:"web_search_options,omitempty"`
+	// MaxTokens 和 MaxCompletionTokens 由代理按估算的 token 数截断输出
+	MaxTokens           int         `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int         `json:"max_completion_tokens,omitempty"`
+	Stop                interface{} `json:"stop,omitempty"`
+	// ReasoningEffort 为 OpenAI 格式，Thinking 为 Anthropic 格式，均用于控制扩展思考
+	ReasoningEffort string          `json:"reasoning_effort,omitempty"`
+	Thinking        *ThinkingConfig `json:"thinking,omitempty"`
+	ResponseFormat  *ResponseFormat `json:"response_format,omitempty"`
+	// Style 为回复风格，如 Concise、Explanatory、Formal 或自定义风格名
+	Style string `json:"style,omitempty"`
+}
+
+// ResponseFormat 对应 OpenAI 的 response_format 参数
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat 描述 json_schema 模式下要求的输出结构
+type JSONSchemaFormat struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
+// IsJSON 判断是否要求 JSON 输出
+func (f *ResponseFormat) IsJSON() bool {
+	return f != nil && (f.Type == "json_object" || f.Type == "json_schema")
+}
+
+// Schema 返回 json_schema 模式下的 schema，其它模式返回 nil
+func (f *ResponseFormat) Schema() map[string]interface{} {
+	if f == nil || f.Type != "json_schema" || f.JSONSchema == nil {
+		return nil
+	}
+	return f.JSONSchema.Schema
+}
+
+// ThinkingConfig 对应 Anthropic 的 thinking 参数
+type ThinkingConfig struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// ThinkingEnabled 根据 thinking 和 reasoning_effort 判断是否开启思考，ok 为 false 表示请求未指定
+func (r *ChatCompletionRequest) ThinkingEnabled() (enabled bool, ok bool) {
+	if r.Thinking != nil {
+		switch r.Thinking.Type {
+		case "enabled":
+			return true, true
+		case "disabled":
+			return false, true
+		}
+		if r.Thinking.BudgetTokens > 0 {
+			return true, true
+		}
+	}
+	switch r.ReasoningEffort {
+	case "low", "medium", "high":
+		return true, true
+	case "none", "minimal":
+		return false, true
+	}
+	return false, false
+}
+
+// OutputTokenLimit 返回输出 token 上限，max_completion_tokens 优先
+func (r *ChatCompletionRequest) OutputTokenLimit() int {
+	if r.MaxCompletionTokens > 0 {
+		return r.MaxCompletionTokens
+	}
+	return r.MaxTokens
+}
+
+// StopSequences 返回停止序列，stop 可以是字符串或字符串数组
+func (r *ChatCompletionRequest) StopSequences() []string {
+	switch v := r.Stop.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		stops := []string{}
+		for _, item := range v {
+			if stop, ok := item.(string); ok {
+				stops = append(stops, stop)
+			}
+		}
+		return stops
+	}
+	return nil
+}
+
+// Annotation 表示消息中的引用注释，目前只有 url_citation 一种
+type Annotation struct {
+	Type        string      `json:"type"`
+	URLCitation URLCitation `json:"url_citation"`
+}
+
+// URLCitation 记录联网搜索引用的来源及其在消息中的字符区间
+type URLCitation struct {
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+}
+
+// NewURLCitation 创建从 startIndex 开始的 url_citation 注释
+func NewURLCitation(url string, title string, startIndex int) Annotation {
+	return Annotation{
+		Type: "url_citation",
+		URLCitation: URLCitation{
+			StartIndex: startIndex,
+			EndIndex:   startIndex,
+			Title:      title,
+			URL:        url,
+		},
+	}
 }
 
 // OpenAISrteamResponse 定义 OpenAI 的流式响应结构
 type OpenAISrteamResponse struct {
-	ID      string         `json:"id"`
-	Object  string         `json:"object"`
-	Created int64          `json:"created"`
-	Model   string         `json:"model"`
-	Choices []StreamChoice `json:"choices"`
+	ID        string         `json:"id"`
+	Object    string         `json:"object"`
+	Created   int64          `json:"created"`
+	Model     string         `json:"model"`
+	Choices   []StreamChoice `json:"choices"`
+	ToolsUsed []string       `json:"tools_used,omitempty"`
 }
 
 // Choice 结构表示 OpenAI 返回的单个选项
@@ -43,22 +162,24 @@ type NoStreamChoice struct {
 
 // Delta 结构用于存储返回的文本内容
 type Delta struct {
-	Content string `json:"content"`
+	Content     string       `json:"content"`
+	Annotations []Annotation `json:"annotations,omitempty"`
 }
 type Message struct {
-	Role       string        `json:"role"`
-	Content    string        `json:"content"`
-	Refusal    interface{}   `json:"refusal"`
-	Annotation []interface{} `json:"annotation"`
+	Role        string       `json:"role"`
+	Content     string       `json:"content"`
+	Refusal     interface{}  `json:"refusal"`
+	Annotations []Annotation `json:"annotations"`
 }
 
 type OpenAIResponse struct {
-	ID      string           `json:"id"`
-	Object  string           `json:"object"`
-	Created int64            `json:"created"`
-	Model   string           `json:"model"`
-	Choices []NoStreamChoice `json:"choices"`
-	Usage   Usage            `json:"usage"`
+	ID        string           `json:"id"`
+	Object    string           `json:"object"`
+	Created   int64            `json:"created"`
+	Model     string           `json:"model"`
+	Choices   []NoStreamChoice `json:"choices"`
+	Usage     Usage            `json:"usage"`
+	ToolsUsed []string         `json:"tools_used,omitempty"`
 }
 type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
@@ -66,65 +187,165 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
-func ReturnOpenAIResponse(text string, stream bool, gc *gin.Context) error {
-	if stream {
-		return streamRespose(text, gc)
-	} else {
-		return noStreamResponse(text, gc)
+// OpenAIChatWriter 将 Claude 的输出渲染为 OpenAI chat.completion 格式
+type OpenAIChatWriter struct {
+	gc                *gin.Context
+	stream            bool
+	model             string
+	streamAnnotations bool
+	id                string
+	created           int64
+	thinkingShown     bool
+	content           strings.Builder
+	annotations       []Annotation
+	// 思考内容和标签穿插在正文中，记录正文长度与渲染长度的偏移，用于换算引用位置
+	textLen     int
+	renderedLen int
+	shifts      []textShift
+}
+
+type textShift struct {
+	textLen int
+	shift   int
+}
+
+// NewOpenAIChatWriter creates a writer for /v1/chat/completions responses
+func NewOpenAIChatWriter(gc *gin.Context, stream bool, model string, streamAnnotations bool) *OpenAIChatWriter {
+	return &OpenAIChatWriter{
+		gc:                gc,
+		stream:            stream,
+		model:             model,
+		streamAnnotations: streamAnnotations,
+		id:                "chatcmpl-" + uuid.New().String(),
+		created:           time.Now().Unix(),
 	}
 }
 
-func streamRespose(text string, gc *gin.Context) error {
-	openAIResp := &OpenAISrteamResponse{
-		ID:      uuid.New().String(),
+func (w *OpenAIChatWriter) Start() error {
+	if w.stream {
+		StartEventStream(w.gc)
+	}
+	return nil
+}
+
+func (w *OpenAIChatWriter) WriteText(text string) error {
+	textRunes := utf8.RuneCountInString(text)
+	if w.thinkingShown {
+		w.thinkingShown = false
+		return w.write("</think>\n"+text, textRunes)
+	}
+	return w.write(text, textRunes)
+}
+
+func (w *OpenAIChatWriter) WriteThinking(text string) error {
+	if !w.thinkingShown {
+		w.thinkingShown = true
+		text = "<think>" + text
+	}
+	return w.write(text, 0)
+}
+
+func (w *OpenAIChatWriter) WriteAnnotation(annotation Annotation) error {
+	// 引用位置基于纯正文计算，换算为包含思考内容的位置
+	startShift := 0
+	for _, s := range w.shifts {
+		if s.textLen > annotation.URLCitation.StartIndex {
+			break
+		}
+		startShift = s.shift
+	}
+	annotation.URLCitation.StartIndex += startShift
+	annotation.URLCitation.EndIndex += w.renderedLen - w.textLen
+	w.annotations = append(w.annotations, annotation)
+	if !w.stream || !w.streamAnnotations {
+		return nil
+	}
+	return w.writeChunk(Delta{Annotations: []Annotation{annotation}}, nil)
+}
+
+func (w *OpenAIChatWriter) WriteError(message string) error {
+	if w.stream {
+		return w.writeChunk(Delta{Content: message}, nil)
+	}
+	w.gc.JSON(200, w.response(message, CompletionInfo{FinishReason: "stop"}))
+	return nil
+}
+
+func (w *OpenAIChatWriter) Finish(info CompletionInfo) error {
+	if info.FinishReason == "" {
+		info.FinishReason = "stop"
+	}
+	if !w.stream {
+		w.gc.JSON(200, w.response(w.content.String(), info))
+		return nil
+	}
+	openAIResp := w.chunk(Delta{}, info.FinishReason)
+	openAIResp.ToolsUsed = info.ToolsUsed
+	if err := WriteEventData(w.gc, openAIResp); err != nil {
+		return err
+	}
+	// 发送结束标志
+	EndEventStream(w.gc)
+	return nil
+}
+
+// write 输出渲染后的内容，textRunes 为其中正文的字符数，其余部分（位于开头）计入偏移
+func (w *OpenAIChatWriter) write(text string, textRunes int) error {
+	w.content.WriteString(text)
+	w.renderedLen += utf8.RuneCountInString(text)
+	if shift := w.renderedLen - w.textLen - textRunes; len(w.shifts) == 0 || w.shifts[len(w.shifts)-1].shift != shift {
+		w.shifts = append(w.shifts, textShift{textLen: w.textLen, shift: shift})
+	}
+	w.textLen += textRunes
+	if !w.stream {
+		return nil
+	}
+	return w.writeChunk(Delta{Content: text}, nil)
+}
+
+func (w *OpenAIChatWriter) writeChunk(delta Delta, finishReason interface{}) error {
+	return WriteEventData(w.gc, w.chunk(delta, finishReason))
+}
+
+func (w *OpenAIChatWriter) chunk(delta Delta, finishReason interface{}) *OpenAISrteamResponse {
+	return &OpenAISrteamResponse{
+		ID:      w.id,
 		Object:  "chat.completion.chunk",
-		Created: time.Now().Unix(),
-		Model:   "claude-3-7-sonnet-20250219",
+		Created: w.created,
+		Model:   w.model,
 		Choices: []StreamChoice{
 			{
-				Index: 0,
-				Delta: Delta{
-					Content: text,
-				},
+				Index:        0,
+				Delta:        delta,
 				Logprobs:     nil,
-				FinishReason: nil,
+				FinishReason: finishReason,
 			},
 		},
 	}
-
-	jsonBytes, err := json.Marshal(openAIResp)
-	jsonBytes = append([]byte("data: "), jsonBytes...)
-	jsonBytes = append(jsonBytes, []byte("\n\n")...)
-	if err != nil {
-		logger.Error(fmt.Sprintf("Error marshalling JSON: %v", err))
-		return err
-	}
-
-	// 发送数据
-	gc.Writer.Write(jsonBytes)
-	gc.Writer.Flush()
-	return nil
 }
 
-func noStreamResponse(text string, gc *gin.Context) error {
-	openAIResp := &OpenAIResponse{
-		ID:      uuid.New().String(),
+func (w *OpenAIChatWriter) response(text string, info CompletionInfo) *OpenAIResponse {
+	annotations := w.annotations
+	if annotations == nil {
+		annotations = []Annotation{}
+	}
+	return &OpenAIResponse{
+		ID:      w.id,
 		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   "claude-3-7-sonnet-20250219",
+		Created: w.created,
+		Model:   w.model,
 		Choices: []NoStreamChoice{
 			{
 				Index: 0,
 				Message: Message{
-					Role:    "assistant",
-					Content: text,
+					Role:        "assistant",
+					Content:     text,
+					Annotations: annotations,
 				},
 				Logprobs:     nil,
-				FinishReason: "stop",
+				FinishReason: info.FinishReason,
 			},
 		},
+		ToolsUsed: info.ToolsUsed,
 	}
-
-	gc.JSON(200, openAIResp)
-	return nil
 }
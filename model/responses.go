@@ -0,0 +1,409 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ResponsesRequest 对应 OpenAI Responses API 的 /v1/responses 请求
+type ResponsesRequest struct {
+	Model              string                   `json:"model"`
+	Input              interface{}              `json:"input"`
+	Instructions       string                   `json:"instructions,omitempty"`
+	Stream             bool                     `json:"stream"`
+	MaxOutputTokens    int                      `json:"max_output_tokens,omitempty"`
+	PreviousResponseID string                   `json:"previous_response_id,omitempty"`
+	Store              *bool                    `json:"store,omitempty"`
+	Reasoning          *ResponsesReasoning      `json:"reasoning,omitempty"`
+	Tools              []map[string]interface{} `json:"tools,omitempty"`
+	Text               *ResponsesTextConfig     `json:"text,omitempty"`
+}
+
+type ResponsesReasoning struct {
+	Effort string `json:"effort,omitempty"`
+}
+
+// ResponsesTextConfig 中的 format 为扁平化的 response_format
+type ResponsesTextConfig struct {
+	Format *ResponsesTextFormat `json:"format,omitempty"`
+}
+
+type ResponsesTextFormat struct {
+	Type   string                 `json:"type"`
+	Name   string                 `json:"name,omitempty"`
+	Schema map[string]interface{} `json:"schema,omitempty"`
+	Strict bool                   `json:"strict,omitempty"`
+}
+
+// ShouldStore 判断是否保存响应，默认保存
+func (r *ResponsesRequest) ShouldStore() bool {
+	return r.Store == nil || *r.Store
+}
+
+// InputMessages 将 instructions 和 input 转换为 chat 格式的消息
+func (r *ResponsesRequest) InputMessages() ([]map[string]interface{}, error) {
+	messages := []map[string]interface{}{}
+	switch v := r.Input.(type) {
+	case string:
+		messages = append(messages, map[string]interface{}{"role": "user", "content": v})
+	case []interface{}:
+		for _, item := range v {
+			itemMap, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if itemType, _ := itemMap["type"].(string); itemType != "" && itemType != "message" {
+				// 函数调用等其它条目不支持，直接跳过
+				continue
+			}
+			role, _ := itemMap["role"].(string)
+			if role == "developer" {
+				role = "system"
+			}
+			messages = append(messages, map[string]interface{}{
+				"role":    role,
+				"content": convertResponsesContent(itemMap["content"]),
+			})
+		}
+	default:
+		return nil, errors.New("input must be a string or an array of input items")
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("no input provided")
+	}
+	return messages, nil
+}
+
+// convertResponsesContent 将 input_text / output_text / input_image 转换为 chat 格式的内容
+func convertResponsesContent(content interface{}) interface{} {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return content
+	}
+	converted := []interface{}{}
+	for _, part := range parts {
+		partMap, ok := part.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch partMap["type"] {
+		case "input_text", "output_text", "text":
+			converted = append(converted, map[string]interface{}{"type": "text", "text": partMap["text"]})
+		case "input_image":
+			imageURL, _ := partMap["image_url"].(string)
+			converted = append(converted, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": imageURL},
+			})
+		}
+	}
+	return converted
+}
+
+// ToChatRequest 将 Responses 请求转换为 chat 请求，history 为 previous_response_id 对应的历史消息
+func (r *ResponsesRequest) ToChatRequest(history []map[string]interface{}) (*ChatCompletionRequest, error) {
+	input, err := r.InputMessages()
+	if err != nil {
+		return nil, err
+	}
+	messages := []map[string]interface{}{}
+	if r.Instructions != "" {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": r.Instructions})
+	}
+	messages = append(messages, history...)
+	messages = append(messages, input...)
+
+	chatReq := &ChatCompletionRequest{
+		Model:               r.Model,
+		Messages:            messages,
+		Stream:              r.Stream,
+		MaxCompletionTokens: r.MaxOutputTokens,
+	}
+	if r.Reasoning != nil {
+		chatReq.ReasoningEffort = r.Reasoning.Effort
+	}
+	for _, tool := range r.Tools {
+		if toolType, _ := tool["type"].(string); strings.HasPrefix(toolType, "web_search") {
+			webSearch := true
+			chatReq.WebSearch = &webSearch
+		}
+	}
+	if r.Text != nil && r.Text.Format != nil && r.Text.Format.Type != "text" {
+		chatReq.ResponseFormat = &ResponseFormat{Type: r.Text.Format.Type}
+		if r.Text.Format.Type == "json_schema" {
+			chatReq.ResponseFormat.JSONSchema = &JSONSchemaFormat{
+				Name:   r.Text.Format.Name,
+				Schema: r.Text.Format.Schema,
+				Strict: r.Text.Format.Strict,
+			}
+		}
+	}
+	return chatReq, nil
+}
+
+// ResponseObject 为 Responses API 返回的 response 对象
+type ResponseObject struct {
+	ID                 string               `json:"id"`
+	Object             string               `json:"object"`
+	CreatedAt          int64                `json:"created_at"`
+	Status             string               `json:"status"`
+	Model              string               `json:"model"`
+	Output             []*ResponseItem      `json:"output"`
+	PreviousResponseID *string              `json:"previous_response_id"`
+	IncompleteDetails  *IncompleteDetails   `json:"incomplete_details"`
+	Error              *ResponseObjectError `json:"error"`
+	ToolsUsed          []string             `json:"tools_used,omitempty"`
+}
+
+type IncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+type ResponseObjectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResponseItem 为 response.output 中的条目，message 或 reasoning
+type ResponseItem struct {
+	Type    string                `json:"type"`
+	ID      string                `json:"id"`
+	Status  string                `json:"status,omitempty"`
+	Role    string                `json:"role,omitempty"`
+	Content []*ResponseOutputText `json:"content,omitempty"`
+	Summary []*ResponseSummary    `json:"summary,omitempty"`
+}
+
+// MarshalJSON 按条目类型输出字段，空的 content 和 summary 也需要以数组形式出现
+func (i *ResponseItem) MarshalJSON() ([]byte, error) {
+	item := map[string]interface{}{"type": i.Type, "id": i.ID}
+	switch i.Type {
+	case "message":
+		item["status"] = i.Status
+		item["role"] = i.Role
+		item["content"] = i.Content
+	case "reasoning":
+		item["summary"] = i.Summary
+	}
+	return json.Marshal(item)
+}
+
+type ResponseOutputText struct {
+	Type        string               `json:"type"`
+	Text        string               `json:"text"`
+	Annotations []ResponseAnnotation `json:"annotations"`
+}
+
+type ResponseSummary struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// ResponseAnnotation 为 Responses API 中扁平化的 url_citation
+type ResponseAnnotation struct {
+	Type       string `json:"type"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+}
+
+// ResponsesWriter 将 Claude 的输出渲染为 Responses API 格式，思考内容作为 reasoning 条目输出
+type ResponsesWriter struct {
+	gc       *gin.Context
+	stream   bool
+	sequence int
+	response *ResponseObject
+	current  *ResponseItem
+	// partStart 为当前 message 条目开始前的正文字符数，用于换算引用位置
+	textLen   int
+	partStart int
+	text      strings.Builder
+}
+
+// NewResponsesWriter creates a writer for /v1/responses
+func NewResponsesWriter(gc *gin.Context, stream bool, model string, previousResponseID string) *ResponsesWriter {
+	response := &ResponseObject{
+		ID:        "resp_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:    "response",
+		CreatedAt: time.Now().Unix(),
+		Status:    "in_progress",
+		Model:     model,
+		Output:    []*ResponseItem{},
+	}
+	if previousResponseID != "" {
+		response.PreviousResponseID = &previousResponseID
+	}
+	return &ResponsesWriter{
+		gc:       gc,
+		stream:   stream,
+		response: response,
+	}
+}
+
+// Response 返回 response 对象，完成后可用于保存
+func (w *ResponsesWriter) Response() *ResponseObject {
+	return w.response
+}
+
+// OutputText 返回所有 message 条目的正文
+func (w *ResponsesWriter) OutputText() string {
+	return w.text.String()
+}
+
+func (w *ResponsesWriter) Start() error {
+	if !w.stream {
+		return nil
+	}
+	StartEventStream(w.gc)
+	w.writeEvent("response.created", map[string]interface{}{"response": w.response})
+	w.writeEvent("response.in_progress", map[string]interface{}{"response": w.response})
+	return nil
+}
+
+func (w *ResponsesWriter) WriteText(text string) error {
+	if w.current == nil || w.current.Type != "message" {
+		w.closeItem()
+		w.partStart = w.textLen
+		w.openItem(&ResponseItem{
+			Type:    "message",
+			ID:      "msg_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+			Status:  "in_progress",
+			Role:    "assistant",
+			Content: []*ResponseOutputText{},
+		})
+		part := &ResponseOutputText{Type: "output_text", Annotations: []ResponseAnnotation{}}
+		w.current.Content = append(w.current.Content, part)
+		w.writeItemEvent("response.content_part.added", map[string]interface{}{"content_index": 0, "part": part})
+	}
+	w.current.Content[0].Text += text
+	w.textLen += utf8.RuneCountInString(text)
+	w.text.WriteString(text)
+	w.writeItemEvent("response.output_text.delta", map[string]interface{}{"content_index": 0, "delta": text})
+	return nil
+}
+
+func (w *ResponsesWriter) WriteThinking(text string) error {
+	if w.current == nil || w.current.Type != "reasoning" {
+		w.closeItem()
+		w.openItem(&ResponseItem{
+			Type:    "reasoning",
+			ID:      "rs_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+			Summary: []*ResponseSummary{},
+		})
+		part := &ResponseSummary{Type: "summary_text"}
+		w.current.Summary = append(w.current.Summary, part)
+		w.writeItemEvent("response.reasoning_summary_part.added", map[string]interface{}{"summary_index": 0, "part": part})
+	}
+	w.current.Summary[0].Text += text
+	w.writeItemEvent("response.reasoning_summary_text.delta", map[string]interface{}{"summary_index": 0, "delta": text})
+	return nil
+}
+
+func (w *ResponsesWriter) WriteAnnotation(annotation Annotation) error {
+	if w.current == nil || w.current.Type != "message" {
+		return nil
+	}
+	part := w.current.Content[0]
+	responseAnnotation := ResponseAnnotation{
+		Type:       "url_citation",
+		StartIndex: max(annotation.URLCitation.StartIndex-w.partStart, 0),
+		EndIndex:   annotation.URLCitation.EndIndex - w.partStart,
+		URL:        annotation.URLCitation.URL,
+		Title:      annotation.URLCitation.Title,
+	}
+	part.Annotations = append(part.Annotations, responseAnnotation)
+	w.writeItemEvent("response.output_text.annotation.added", map[string]interface{}{
+		"content_index":    0,
+		"annotation_index": len(part.Annotations) - 1,
+		"annotation":       responseAnnotation,
+	})
+	return nil
+}
+
+func (w *ResponsesWriter) WriteError(message string) error {
+	w.closeItem()
+	w.response.Status = "failed"
+	w.response.Error = &ResponseObjectError{Code: "server_error", Message: message}
+	if !w.stream {
+		w.gc.JSON(200, w.response)
+		return nil
+	}
+	w.writeEvent("error", map[string]interface{}{"code": "server_error", "message": message})
+	w.writeEvent("response.failed", map[string]interface{}{"response": w.response})
+	return nil
+}
+
+func (w *ResponsesWriter) Finish(info CompletionInfo) error {
+	w.closeItem()
+	w.response.ToolsUsed = info.ToolsUsed
+	eventType := "response.completed"
+	if info.FinishReason == "length" {
+		w.response.Status = "incomplete"
+		w.response.IncompleteDetails = &IncompleteDetails{Reason: "max_output_tokens"}
+		eventType = "response.incomplete"
+	} else {
+		w.response.Status = "completed"
+	}
+	if !w.stream {
+		w.gc.JSON(200, w.response)
+		return nil
+	}
+	return w.writeEvent(eventType, map[string]interface{}{"response": w.response})
+}
+
+func (w *ResponsesWriter) openItem(item *ResponseItem) {
+	w.response.Output = append(w.response.Output, item)
+	w.current = item
+	w.writeEvent("response.output_item.added", map[string]interface{}{
+		"output_index": len(w.response.Output) - 1,
+		"item":         item,
+	})
+}
+
+// closeItem 结束当前条目并发送对应的 done 事件
+func (w *ResponsesWriter) closeItem() {
+	item := w.current
+	if item == nil {
+		return
+	}
+	switch item.Type {
+	case "message":
+		part := item.Content[0]
+		w.writeItemEvent("response.output_text.done", map[string]interface{}{"content_index": 0, "text": part.Text})
+		w.writeItemEvent("response.content_part.done", map[string]interface{}{"content_index": 0, "part": part})
+		item.Status = "completed"
+	case "reasoning":
+		part := item.Summary[0]
+		w.writeItemEvent("response.reasoning_summary_text.done", map[string]interface{}{"summary_index": 0, "text": part.Text})
+		w.writeItemEvent("response.reasoning_summary_part.done", map[string]interface{}{"summary_index": 0, "part": part})
+	}
+	w.writeEvent("response.output_item.done", map[string]interface{}{
+		"output_index": len(w.response.Output) - 1,
+		"item":         item,
+	})
+	w.current = nil
+}
+
+// writeItemEvent 发送属于当前条目的事件
+func (w *ResponsesWriter) writeItemEvent(eventType string, data map[string]interface{}) error {
+	data["item_id"] = w.current.ID
+	data["output_index"] = len(w.response.Output) - 1
+	return w.writeEvent(eventType, data)
+}
+
+func (w *ResponsesWriter) writeEvent(eventType string, data map[string]interface{}) error {
+	if !w.stream {
+		return nil
+	}
+	data["type"] = eventType
+	data["sequence_number"] = w.sequence
+	w.sequence++
+	return WriteNamedEventData(w.gc, eventType, data)
+}
@@ -0,0 +1,128 @@
+package model
+
+import (
+	"claude2api/logger"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompletionWriter renders a Claude completion in a client-facing API format
+type CompletionWriter interface {
+	// Start is called once the upstream stream is established
+	Start() error
+	WriteText(text string) error
+	WriteThinking(text string) error
+	// WriteAnnotation receives citations with offsets relative to the text written so far
+	WriteAnnotation(annotation Annotation) error
+	WriteError(message string) error
+	Finish(info CompletionInfo) error
+}
+
+// CompletionInfo 记录响应结束时的附加信息
+type CompletionInfo struct {
+	FinishReason string
+	ToolsUsed    []string
+}
+
+// BufferWriter collects the completion instead of sending it, so the reply
+// can be checked before anything reaches the client. Thinking is dropped.
+type BufferWriter struct {
+	Text         strings.Builder
+	Annotations  []Annotation
+	ErrorMessage string
+	Info         CompletionInfo
+}
+
+// NewBufferWriter creates an empty BufferWriter
+func NewBufferWriter() *BufferWriter {
+	return &BufferWriter{}
+}
+
+func (w *BufferWriter) Start() error {
+	return nil
+}
+
+func (w *BufferWriter) WriteText(text string) error {
+	w.Text.WriteString(text)
+	return nil
+}
+
+func (w *BufferWriter) WriteThinking(text string) error {
+	return nil
+}
+
+func (w *BufferWriter) WriteAnnotation(annotation Annotation) error {
+	w.Annotations = append(w.Annotations, annotation)
+	return nil
+}
+
+func (w *BufferWriter) WriteError(message string) error {
+	w.ErrorMessage = message
+	return nil
+}
+
+func (w *BufferWriter) Finish(info CompletionInfo) error {
+	w.Info = info
+	return nil
+}
+
+// StartEventStream 设置 SSE 响应头并发送 200 状态码
+func StartEventStream(gc *gin.Context) {
+	gc.Writer.Header().Set("Content-Type", "text/event-stream")
+	gc.Writer.Header().Set("Cache-Control", "no-cache")
+	gc.Writer.Header().Set("Connection", "keep-alive")
+	gc.Writer.WriteHeader(http.StatusOK)
+	gc.Writer.Flush()
+}
+
+// WriteEventData 以 SSE data 行发送 JSON 数据
+func WriteEventData(gc *gin.Context, data interface{}) error {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error marshalling JSON: %v", err))
+		return err
+	}
+	jsonBytes = append([]byte("data: "), jsonBytes...)
+	jsonBytes = append(jsonBytes, []byte("\n\n")...)
+
+	// 发送数据
+	gc.Writer.Write(jsonBytes)
+	gc.Writer.Flush()
+	return nil
+}
+
+// WriteNamedEventData 发送带 event 行的 SSE 事件
+func WriteNamedEventData(gc *gin.Context, event string, data interface{}) error {
+	gc.Writer.Write([]byte("event: " + event + "\n"))
+	return WriteEventData(gc, data)
+}
+
+// StartNDJSONStream 设置 NDJSON 响应头并发送 200 状态码
+func StartNDJSONStream(gc *gin.Context) {
+	gc.Writer.Header().Set("Content-Type", "application/x-ndjson")
+	gc.Writer.Header().Set("Cache-Control", "no-cache")
+	gc.Writer.WriteHeader(http.StatusOK)
+	gc.Writer.Flush()
+}
+
+// WriteNDJSON 以单行 JSON 发送数据
+func WriteNDJSON(gc *gin.Context, data interface{}) error {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error marshalling JSON: %v", err))
+		return err
+	}
+	gc.Writer.Write(append(jsonBytes, '\n'))
+	gc.Writer.Flush()
+	return nil
+}
+
+// EndEventStream 发送 OpenAI 风格的结束标志
+func EndEventStream(gc *gin.Context) {
+	gc.Writer.Write([]byte("data: [DONE]\n\n"))
+	gc.Writer.Flush()
+}
@@ -18,11 +18,64 @@ func SetupRoutes(r *gin.Engine) {
 
 	// Chat completions endpoint (OpenAI-compatible)
 	r.POST("/v1/chat/completions", service.ChatCompletionsHandler)
+	r.POST("/v1/completions", service.CompletionsHandler)
+	r.POST("/v1/responses", service.ResponsesHandler)
+	r.GET("/v1/responses/:id", service.GetResponseHandler)
 	r.GET("/v1/models", service.MoudlesHandler)
 
+	// Token counting endpoints
+	r.POST("/v1/count_tokens", service.CountTokensHandler)
+	r.POST("/v1/messages/count_tokens", service.AnthropicCountTokensHandler)
+
+	// Claude projects
+	r.GET("/v1/projects", service.ProjectsHandler)
+
+	// Admin routes, only accessible with ADMIN_KEY (or APIKEY when it is not set)
+	adminRouter := r.Group("/v1", middleware.AdminMiddleware())
+	{
+		// Sync Claude projects
+		adminRouter.POST("/projects/sync", service.SyncProjectsHandler)
+
+		// Session status
+		adminRouter.GET("/sessions", service.SessionsHandler)
+		adminRouter.POST("/sessions/check", service.CheckSessionsHandler)
+		adminRouter.GET("/sessions/:index/organizations", service.OrganizationsHandler)
+
+		// Personalized styles of a pooled session's account
+		adminRouter.GET("/styles", service.StylesHandler)
+
+		// Usage reports
+		adminRouter.GET("/usage", service.UsageHandler)
+		adminRouter.GET("/usage/export", service.UsageExportHandler)
+
+		// Purge leftover conversations
+		adminRouter.POST("/conversations/purge", service.PurgeConversationsHandler)
+	}
+
+	// Ollama compatible routes
+	r.POST("/api/chat", service.OllamaChatHandler)
+	r.POST("/api/generate", service.OllamaGenerateHandler)
+	r.GET("/api/tags", service.OllamaTagsHandler)
+	r.GET("/api/version", service.OllamaVersionHandler)
+
+	// Gemini compatible routes, e.g. /v1beta/models/{model}:generateContent
+	r.POST("/v1beta/models/:action", service.GeminiHandler)
+	r.GET("/v1beta/models", service.GeminiModelsHandler)
+
 	if config.ConfigInstance.EnableMirrorApi {
 		r.POST(config.ConfigInstance.MirrorApiPrefix+"/v1/chat/completions", service.MirrorChatHandler)
+		r.POST(config.ConfigInstance.MirrorApiPrefix+"/v1/completions", service.CompletionsHandler)
+		r.POST(config.ConfigInstance.MirrorApiPrefix+"/v1/responses", service.ResponsesHandler)
+		r.GET(config.ConfigInstance.MirrorApiPrefix+"/v1/responses/:id", service.GetResponseHandler)
 		r.GET(config.ConfigInstance.MirrorApiPrefix+"/v1/models", service.MoudlesHandler)
+		r.GET(config.ConfigInstance.MirrorApiPrefix+"/v1/styles", service.StylesHandler)
+		r.POST(config.ConfigInstance.MirrorApiPrefix+"/v1/count_tokens", service.CountTokensHandler)
+		r.POST(config.ConfigInstance.MirrorApiPrefix+"/v1/messages/count_tokens", service.AnthropicCountTokensHandler)
+		r.POST(config.ConfigInstance.MirrorApiPrefix+"/api/chat", service.OllamaChatHandler)
+		r.POST(config.ConfigInstance.MirrorApiPrefix+"/api/generate", service.OllamaGenerateHandler)
+		r.GET(config.ConfigInstance.MirrorApiPrefix+"/api/tags", service.OllamaTagsHandler)
+		r.POST(config.ConfigInstance.MirrorApiPrefix+"/v1beta/models/:action", service.GeminiHandler)
+		r.GET(config.ConfigInstance.MirrorApiPrefix+"/v1beta/models", service.GeminiModelsHandler)
 	}
 
 	// HuggingFace compatible routes
@@ -31,7 +84,11 @@ func SetupRoutes(r *gin.Engine) {
 		v1Router := hfRouter.Group("/v1")
 		{
 			v1Router.POST("/chat/completions", service.ChatCompletionsHandler)
+			v1Router.POST("/completions", service.CompletionsHandler)
+			v1Router.POST("/responses", service.ResponsesHandler)
+			v1Router.GET("/responses/:id", service.GetResponseHandler)
 			v1Router.GET("/models", service.MoudlesHandler)
+			v1Router.POST("/count_tokens", service.CountTokensHandler)
 		}
 	}
 }
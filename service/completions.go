@@ -0,0 +1,37 @@
+package service
+
+import (
+	"claude2api/model"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompletionsHandler handles the legacy text completions endpoint
+func CompletionsHandler(c *gin.Context) {
+	req := model.CompletionRequest{
+		Stream: false,
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	chatReq, err := req.ToChatRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	// Wrap the prompt into the chat flow
+	opts := buildChatOptions(chatReq)
+	processor := processMessages(opts, chatReq.Messages)
+	opts.NewWriter = func() model.CompletionWriter {
+		return model.NewTextCompletionWriter(c, opts.Stream, opts.Alias)
+	}
+	serveRequest(c, opts, processor)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"claude2api/model"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GeminiHandler handles generateContent and streamGenerateContent, the path
+// parameter has the form "{model}:{method}"
+func GeminiHandler(c *gin.Context) {
+	modelName, method, ok := strings.Cut(strings.TrimPrefix(c.Param("action"), "/"), ":")
+	if !ok || (method != "generateContent" && method != "streamGenerateContent") {
+		geminiError(c, http.StatusNotFound, fmt.Sprintf("Unknown method: %s", c.Param("action")))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		geminiError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	req, err := model.ParseGeminiRequest(body)
+	if err != nil {
+		geminiError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	stream := method == "streamGenerateContent"
+	chatReq, err := req.ToChatRequest(modelName, stream)
+	if err != nil {
+		geminiError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	opts := buildChatOptions(chatReq)
+	processor := processMessages(opts, chatReq.Messages)
+	sse := c.Query("alt") == "sse"
+	opts.NewWriter = func() model.CompletionWriter {
+		return model.NewGeminiWriter(c, opts.Stream, sse, opts.Alias, req.IncludeThoughts())
+	}
+	serveRequest(c, opts, processor)
+}
+
+// GeminiModelsHandler lists the available models in Gemini's format
+func GeminiModelsHandler(c *gin.Context) {
+	models := []gin.H{}
+	for _, name := range availableModels() {
+		models = append(models, gin.H{
+			"name":                       "models/" + name,
+			"displayName":                name,
+			"supportedGenerationMethods": []string{"generateContent", "streamGenerateContent"},
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"models": models})
+}
+
+func geminiError(c *gin.Context, code int, message string) {
+	c.JSON(code, gin.H{"error": gin.H{
+		"code":    code,
+		"message": message,
+		"status":  http.StatusText(code),
+	}})
+}
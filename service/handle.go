@@ -6,9 +6,13 @@ import (
 	"claude2api/logger"
 	"claude2api/model"
 	"claude2api/utils"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,6 +22,39 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// errInvalidJSONResponse 表示回复不符合 response_format 的要求
+var errInvalidJSONResponse = errors.New("response is not valid JSON for the requested response_format")
+
+// chatOptions 保存单次请求在重试之间共享的参数
+type chatOptions struct {
+	// Alias 为客户端请求的模型名，Model 为实际使用的 Claude 模型
+	Alias    string
+	Model    string
+	Thinking bool
+	Stream   bool
+	Tools    []map[string]interface{}
+	Response core.ResponseOptions
+	// PromptTemplate 为别名或全局配置的提示词模板
+	PromptTemplate string
+	// ContextStrategy 为提示词过长时的处理方式
+	ContextStrategy string
+	// Project 为模型别名指定的项目，可被 X-Claude-Project 请求头覆盖
+	Project string
+	// Style 为请求、别名或全局配置的回复风格，可被 X-Claude-Style 请求头覆盖
+	Style string
+	// ResponseFormat 要求 JSON 输出时，回复会在校验通过后才发送
+	ResponseFormat *model.ResponseFormat
+	// NewWriter 为每次尝试创建响应渲染器，为空时使用 OpenAI chat 格式
+	NewWriter func() model.CompletionWriter
+}
+
+func (o chatOptions) newWriter(c *gin.Context) model.CompletionWriter {
+	if o.NewWriter != nil {
+		return o.NewWriter()
+	}
+	return model.NewOpenAIChatWriter(c, o.Stream, o.Alias, config.ConfigInstance.StreamAnnotations)
+}
+
 // HealthCheckHandler handles the health check endpoint
 func HealthCheckHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -42,30 +79,47 @@ func ChatCompletionsHandler(c *gin.Context) {
 		return
 	}
 
+	// Resolve model alias and per-request options
+	opts := buildChatOptions(req)
+
 	// Process messages into prompt and extract images
-	processor := utils.NewChatRequestProcessor()
-	processor.ProcessMessages(req.Messages)
+	processor := processMessages(opts, req.Messages)
+	serveChatRequest(c, opts, processor)
+}
 
-	// Get model or use default
-	model := getModelOrDefault(req.Model)
+// serveChatRequest 使用会话池处理请求，失败时换用其它会话重试
+func serveChatRequest(c *gin.Context, opts chatOptions, processor *utils.ChatRequestProcessor) {
+	if !checkResponseFormat(c, opts.ResponseFormat) {
+		return
+	}
+	model := opts.Model
+	// 整个请求使用同一份会话池快照，会话展开不影响正在进行的重试
+	sessions, retryCount := config.ConfigInstance.SessionPool()
 	index := config.Sr.NextIndex()
+	start := time.Now()
+	var lastErr error
+	var lastSession config.SessionInfo
+	attempts := 0
 	// Attempt with retry mechanism
-	for i := 0; i < config.ConfigInstance.RetryCount; i++ {
-		index = (index + 1) % len(config.ConfigInstance.Sessions)
-		session, err := config.ConfigInstance.GetSessionForModel(index)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Failed to get session for model %s: %v", model, err))
-			logger.Info("Retrying another session")
+	for i := 0; i < retryCount && len(sessions) > 0; i++ {
+		index = (index + 1) % len(sessions)
+		session := sessions[index]
+
+		if sessionExpired(session) {
+			logger.Warn(fmt.Sprintf("Skipping expired session %s", session.Label()))
 			continue
 		}
-
-		logger.Info(fmt.Sprintf("Using session for model %s: %s", model, session.SessionKey))
+		lastSession = session
+		attempts++
+		logger.Info(fmt.Sprintf("Using session for model %s: %s", model, session.Label()))
 		if i > 0 {
 			processor.Prompt.Reset()
 			processor.Prompt.WriteString(processor.RootPrompt.String())
 		}
 		// Initialize client and process request
-		if handleChatRequest(c, session, model, processor, req.Stream) {
+		result, err := handleChatRequest(c, session, opts, processor)
+		if lastErr = err; lastErr == nil {
+			recordUsage(c, opts, session, processor, result, attempts-1, start)
 			return // Success, exit the retry loop
 		}
 
@@ -74,14 +128,41 @@ func ChatCompletionsHandler(c *gin.Context) {
 	}
 
 	logger.Error("Failed for all retries")
+	recordUsage(c, opts, lastSession, processor, nil, attempts-1, start)
+	if errors.Is(lastErr, errInvalidJSONResponse) {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{Error: lastErr.Error()})
+		return
+	}
 	c.JSON(http.StatusInternalServerError, ErrorResponse{
 		Error: "Failed to process request after multiple attempts"})
 }
 
+// serveRequest 根据鉴权方式选择镜像会话或会话池处理请求
+func serveRequest(c *gin.Context, opts chatOptions, processor *utils.ChatRequestProcessor) {
+	useMirror, exist := c.Get("UseMirrorApi")
+	if exist && useMirror.(bool) {
+		serveMirrorRequest(c, opts, processor)
+		return
+	}
+	serveChatRequest(c, opts, processor)
+}
+
+// availableModels 返回内置模型和配置的模型别名
+func availableModels() []string {
+	models := []string{
+		"claude-3-7-sonnet-20250219",
+		"claude-3-7-sonnet-20250219-think",
+	}
+	for _, alias := range config.ConfigInstance.ModelAliases {
+		models = append(models, alias.Name)
+	}
+	return models
+}
+
 func MoudlesHandler(c *gin.Context) {
-	models := []map[string]interface{}{
-		{"id": "claude-3-7-sonnet-20250219"},
-		{"id": "claude-3-7-sonnet-20250219-think"},
+	models := []map[string]interface{}{}
+	for _, name := range availableModels() {
+		models = append(models, map[string]interface{}{"id": name})
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"data": models,
@@ -105,12 +186,26 @@ func MirrorChatHandler(c *gin.Context) {
 		return
 	}
 
+	// Resolve model alias and per-request options
+	opts := buildChatOptions(req)
+
 	// Process messages into prompt and extract images
-	processor := utils.NewChatRequestProcessor()
-	processor.ProcessMessages(req.Messages)
+	processor := processMessages(opts, req.Messages)
+
+	serveMirrorRequest(c, opts, processor)
+}
 
-	// Get model or use default
-	model := getModelOrDefault(req.Model)
+// serveMirrorRequest 使用请求头中的会话处理请求
+func serveMirrorRequest(c *gin.Context, opts chatOptions, processor *utils.ChatRequestProcessor) {
+	if !checkResponseFormat(c, opts.ResponseFormat) {
+		return
+	}
+	if !config.ConfigInstance.EnableMirrorApi {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error: "Mirror API is not enabled",
+		})
+		return
+	}
 
 	// Extract session info from auth header
 	session, err := extractSessionFromAuthHeader(c)
@@ -122,7 +217,14 @@ func MirrorChatHandler(c *gin.Context) {
 	}
 
 	// Process the request with the provided session
-	if !handleChatRequest(c, session, model, processor, req.Stream) {
+	start := time.Now()
+	result, err := handleChatRequest(c, session, opts, processor)
+	recordUsage(c, opts, session, processor, result, 0, start)
+	if err != nil {
+		if errors.Is(err, errInvalidJSONResponse) {
+			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: "Failed to process request",
 		})
@@ -163,6 +265,104 @@ func getModelOrDefault(model string) string {
 	return model
 }
 
+// buildChatOptions 根据模型别名和请求参数确定本次请求的模型、思考模式、工具与输出限制
+func buildChatOptions(req *model.ChatCompletionRequest) chatOptions {
+	alias := config.ConfigInstance.GetModelAlias(getModelOrDefault(req.Model))
+
+	// 联网搜索：请求参数 > 模型别名 > 全局配置
+	webSearch := config.ConfigInstance.WebSearch
+	if alias.WebSearch != nil {
+		webSearch = *alias.WebSearch
+	}
+	if req.WebSearchOptions != nil {
+		webSearch = true
+	}
+	if req.WebSearch != nil {
+		webSearch = *req.WebSearch
+	}
+	toolNames := []string{}
+	if webSearch {
+		toolNames = append(toolNames, "web_search")
+	}
+
+	// 思考模式：请求中的 thinking / reasoning_effort > 模型名 -think 后缀
+	modelName, thinking := core.ParseModelName(alias.Model)
+	if enabled, ok := req.ThinkingEnabled(); ok {
+		thinking = enabled
+	}
+	if req.Thinking != nil && req.Thinking.BudgetTokens > 0 {
+		logger.Debug(fmt.Sprintf("Thinking budget %d is not supported by claude.ai, only enabling thinking", req.Thinking.BudgetTokens))
+	}
+
+	promptTemplate := config.ConfigInstance.PromptTemplate
+	if alias.PromptTemplate != "" {
+		promptTemplate = alias.PromptTemplate
+	}
+	// 回复风格：请求参数 > 模型别名 > 全局配置
+	style := config.ConfigInstance.Style
+	if alias.Style != "" {
+		style = alias.Style
+	}
+	if req.Style != "" {
+		style = req.Style
+	}
+	contextStrategy := config.ConfigInstance.ContextStrategy
+	if alias.ContextStrategy != "" {
+		contextStrategy = alias.ContextStrategy
+	}
+
+	return chatOptions{
+		Alias:    alias.Name,
+		Model:    modelName,
+		Thinking: thinking,
+		Stream:   req.Stream,
+		Tools:    core.WebTools(toolNames...),
+		Response: core.ResponseOptions{
+			MaxTokens:     req.OutputTokenLimit(),
+			Stop:          req.StopSequences(),
+			AbortUpstream: config.ConfigInstance.AbortOnTruncate,
+		},
+		ResponseFormat:  req.ResponseFormat,
+		PromptTemplate:  promptTemplate,
+		ContextStrategy: contextStrategy,
+		Project:         alias.Project,
+		Style:           style,
+	}
+}
+
+// processMessages 使用请求对应的提示词模板处理消息，并追加 JSON 输出要求
+func processMessages(opts chatOptions, messages []map[string]interface{}) *utils.ChatRequestProcessor {
+	processor := utils.NewChatRequestProcessor()
+	processor.Template = utils.GetPromptTemplate(opts.PromptTemplate)
+	processor.ProcessMessages(messages)
+	applyResponseFormat(processor, opts.ResponseFormat)
+	return processor
+}
+
+// checkResponseFormat 在请求上游前检查 response_format 中的 schema，无法使用时返回 400
+func checkResponseFormat(c *gin.Context, format *model.ResponseFormat) bool {
+	if err := utils.CheckSchema(format.Schema()); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid response_format: %v", err),
+		})
+		return false
+	}
+	return true
+}
+
+// applyResponseFormat 在提示词末尾追加 JSON 输出要求
+func applyResponseFormat(processor *utils.ChatRequestProcessor, format *model.ResponseFormat) {
+	if !format.IsJSON() {
+		return
+	}
+	instruction := "Respond with valid JSON only. Do not wrap it in markdown code fences and do not add any other text."
+	if schema := format.Schema(); schema != nil {
+		schemaJSON, _ := json.MarshalIndent(schema, "", "  ")
+		instruction += "\nThe JSON must conform to this JSON schema:\n" + string(schemaJSON)
+	}
+	processor.AppendSystemInstruction(instruction)
+}
+
 func extractSessionFromAuthHeader(c *gin.Context) (config.SessionInfo, error) {
 	authInfo := c.Request.Header.Get("Authorization")
 	authInfo = strings.TrimPrefix(authInfo, "Bearer ")
@@ -179,71 +379,245 @@ func extractSessionFromAuthHeader(c *gin.Context) (config.SessionInfo, error) {
 	return config.SessionInfo{SessionKey: authInfo, OrgID: ""}, nil
 }
 
-func handleChatRequest(c *gin.Context, session config.SessionInfo, model string, processor *utils.ChatRequestProcessor, stream bool) bool {
-	// Initialize the Claude client
-	claudeClient := core.NewClient(session.SessionKey, config.ConfigInstance.Proxy)
+// sessionClient 返回会话的客户端，未知组织 ID 时先查询并保存
+func sessionClient(session config.SessionInfo) (*core.Client, error) {
+	proxy, err := config.ConfigInstance.ProxyForSession(session)
+	if err != nil {
+		return nil, err
+	}
+	client, _ := core.GetClient(session.SessionKey, proxy)
+	orgID, err := resolveOrgID(client, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get org ID: %w", err)
+	}
+	client.SetOrgID(orgID)
+	return client, nil
+}
 
-	// Get org ID if not already set
-	if session.OrgID == "" {
-		orgId, err := claudeClient.GetOrgID()
-		if err != nil {
-			logger.Error(fmt.Sprintf("Failed to get org ID: %v", err))
-			return false
-		}
-		session.OrgID = orgId
-		config.ConfigInstance.SetSessionOrgID(session.SessionKey, session.OrgID)
+func handleChatRequest(c *gin.Context, session config.SessionInfo, opts chatOptions, processor *utils.ChatRequestProcessor) (*core.CompletionResult, error) {
+	// Get the pooled Claude client of the session
+	start := time.Now()
+	proxy, err := config.ConfigInstance.ProxyForSession(session)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to pick proxy: %v", err))
+		return nil, err
 	}
+	claudeClient, reused := core.GetClient(session.SessionKey, proxy)
 
+	// Get org ID if not already set
+	orgID, err := resolveOrgID(claudeClient, session)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to get org ID: %v", err))
+		return nil, err
+	}
+	session.OrgID = orgID
 	claudeClient.SetOrgID(session.OrgID)
 
+	// Compose the request payload without touching the shared client
+	payload := core.NewCompletionPayload().SetTools(opts.Tools)
+
 	// Upload images if any
 	if len(processor.ImgDataList) > 0 {
-		err := claudeClient.UploadFile(processor.ImgDataList)
+		fileUUIDs, err := uploadFiles(claudeClient, processor.ImgDataList)
 		if err != nil {
 			logger.Error(fmt.Sprintf("Failed to upload file: %v", err))
-			return false
+			return nil, err
 		}
+		payload.AddFiles(fileUUIDs...)
 	}
 
 	// Handle large context if needed
-	if processor.Prompt.Len() > config.ConfigInstance.MaxChatHistoryLength {
-		claudeClient.SetBigContext(processor.Prompt.String())
-		processor.ResetForBigContext()
-		logger.Info(fmt.Sprintf("Prompt length exceeds max limit (%d), using file context", config.ConfigInstance.MaxChatHistoryLength))
+	if processor.Overflows() {
+		files, strategy := processor.FitContext(opts.ContextStrategy)
+		for _, file := range files {
+			payload.AddAttachment(file.Name, file.Content)
+		}
+		logger.Info(fmt.Sprintf("Prompt exceeds max context, applied %s strategy with %d attachments", strategy, len(files)))
+	}
+	payload.SetPrompt(processor.Prompt.String())
+
+	// Resolve the project the conversation belongs to
+	project := opts.Project
+	if header := c.GetHeader("X-Claude-Project"); header != "" {
+		project = header
+	}
+	projectUUID, err := resolveProject(claudeClient, project)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to prepare project %s: %v", project, err))
+		return nil, err
+	}
+
+	// Resolve the personalized style of the reply
+	styleName := opts.Style
+	if header := c.GetHeader("X-Claude-Style"); header != "" {
+		styleName = header
 	}
+	payload.SetStyle(resolveStyle(claudeClient, styleName))
+	timezone, locale := resolveLocale(c)
+	payload.SetTimezone(timezone).SetLocale(locale)
 
 	// Create conversation
-	conversationID, err := claudeClient.CreateConversation(model)
+	conversationID, err := claudeClient.CreateConversation(core.ConversationOptions{
+		Model:       opts.Model,
+		Thinking:    opts.Thinking,
+		ProjectUUID: projectUUID,
+		Name:        config.ConfigInstance.ConversationPrefix,
+	})
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to create conversation: %v", err))
-		return false
+		if errors.Is(err, core.ErrProjectNotFound) {
+			// 项目已在上游被删除，下次使用时重新创建
+			claudeClient.ForgetProject(project)
+		}
+		return nil, err
 	}
-
-	// Send message
-	if _, err := claudeClient.SendMessage(conversationID, processor.Prompt.String(), stream, c); err != nil {
+	trackConversation(session, conversationID)
+	logger.Info(fmt.Sprintf("Conversation ready in %v (client reused: %t)", time.Since(start), reused))
+
+	// Send message, JSON replies are buffered so they can be validated before reaching the client
+	writer := opts.newWriter(c)
+	var sendWriter model.CompletionWriter = writer
+	var buffer *model.BufferWriter
+	if opts.ResponseFormat.IsJSON() {
+		buffer = model.NewBufferWriter()
+		sendWriter = buffer
+	}
+	result, _, err := claudeClient.SendMessage(c.Request.Context(), conversationID, payload, sendWriter, opts.Response)
+	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to send message: %v", err))
-		go cleanupConversation(claudeClient, conversationID, 3)
-		return false
+		scheduleCleanup(claudeClient, conversationID)
+		return nil, err
+	}
+	if buffer != nil {
+		if err := deliverJSONResponse(buffer, writer, opts.ResponseFormat); err != nil {
+			logger.Error(fmt.Sprintf("Invalid JSON response: %v", err))
+			scheduleCleanup(claudeClient, conversationID)
+			return nil, err
+		}
+	}
+	logger.Info(fmt.Sprintf("Completion finished, stop reason: %s, content blocks: %d", result.StopReason, len(result.Blocks)))
+	if result.RateLimited() {
+		logger.Warn(fmt.Sprintf("Session %s reached its message limit, resets at %s", session.Label(), time.Unix(result.MessageLimit.ResetsAt, 0)))
 	}
 
 	// Clean up conversation if enabled
 	if config.ConfigInstance.ChatDelete {
-		go cleanupConversation(claudeClient, conversationID, 3)
+		scheduleCleanup(claudeClient, conversationID)
+	} else {
+		forgetConversation(conversationID)
 	}
 
-	return true
+	return result, nil
+}
+
+// deliverJSONResponse 清理并校验缓冲的回复，通过后再发送给客户端
+func deliverJSONResponse(buffer *model.BufferWriter, writer model.CompletionWriter, format *model.ResponseFormat) error {
+	if buffer.ErrorMessage != "" {
+		return fmt.Errorf("%w: %s", errInvalidJSONResponse, buffer.ErrorMessage)
+	}
+	text := utils.ExtractJSON(buffer.Text.String())
+	if err := utils.ValidateJSON(text, format.Schema()); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidJSONResponse, err)
+	}
+	writer.Start()
+	writer.WriteText(text)
+	writer.Finish(buffer.Info)
+	return nil
+}
+
+// pendingCleanups 记录尚未完成的对话删除，退出前等待其完成
+var pendingCleanups sync.WaitGroup
+
+// cleanupsClosing 在开始退出后置为 true，之后不再安排新的删除，避免 Add 与 Wait 竞争
+var (
+	cleanupsMutex   sync.Mutex
+	cleanupsClosing bool
+)
+
+// scheduleCleanup deletes the conversation in the background. Once shutdown
+// has started the conversation stays in the ledger for the next sweep.
+func scheduleCleanup(client *core.Client, conversationID string) {
+	cleanupsMutex.Lock()
+	defer cleanupsMutex.Unlock()
+	if cleanupsClosing {
+		logger.Warn(fmt.Sprintf("Shutting down, leaving conversation %s to the sweeper", conversationID))
+		return
+	}
+	pendingCleanups.Add(1)
+	go func() {
+		defer pendingCleanups.Done()
+		cleanupConversation(client, conversationID, 3)
+	}()
+}
+
+// WaitForCleanups stops scheduling conversation deletions and waits for the
+// scheduled ones until ctx is done
+func WaitForCleanups(ctx context.Context) error {
+	cleanupsMutex.Lock()
+	cleanupsClosing = true
+	cleanupsMutex.Unlock()
+	return waitGroup(ctx, &pendingCleanups)
+}
+
+// backgroundTasks 记录会话检查和对话清理等定时任务，退出前等待其结束
+var backgroundTasks sync.WaitGroup
+
+// runPeriodically calls task every interval until ctx is done
+func runPeriodically(ctx context.Context, interval time.Duration, task func()) {
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				task()
+			}
+		}
+	}()
+}
+
+// WaitForBackgroundTasks waits until the periodic tasks have exited after
+// their context was cancelled, or until ctx is done
+func WaitForBackgroundTasks(ctx context.Context) error {
+	return waitGroup(ctx, &backgroundTasks)
+}
+
+// waitGroup waits for wg until ctx is done
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func cleanupConversation(client *core.Client, conversationID string, retry int) {
 	for i := 0; i < retry; i++ {
-		if err := client.DeleteConversation(conversationID); err != nil {
+		err := client.DeleteConversation(conversationID)
+		if errors.Is(err, core.ErrConversationNotFound) {
+			// 对话已不存在，无需重试
+			forgetConversation(conversationID)
+			return
+		}
+		if err != nil {
 			logger.Error(fmt.Sprintf("Failed to delete conversation: %v", err))
 			time.Sleep(2 * time.Second)
 			continue
 		}
 		logger.Info(fmt.Sprintf("Successfully deleted conversation: %s", conversationID))
+		forgetConversation(conversationID)
 		return // 成功后直接返回，不执行后面的错误日志
 	}
-	// 只有当所有重试都失败后，才会执行到这里
-	logger.Error(fmt.Sprintf("Cleanup %s conversation %s failed after %d retries", client.SessionKey, conversationID, retry))
+	// 只有当所有重试都失败后，才会执行到这里，对话留在记录中等待清理任务删除
+	logger.Error(fmt.Sprintf("Cleanup %s conversation %s failed after %d retries", config.MaskKey(client.SessionKey), conversationID, retry))
 }
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/logger"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveLocale 返回发送给 claude.ai 的时区和语言：
+// X-Claude-Timezone / X-Claude-Locale 请求头 > API 密钥配置 > 全局配置
+func resolveLocale(c *gin.Context) (string, string) {
+	timezone := config.ConfigInstance.Timezone
+	locale := config.ConfigInstance.Locale
+	if value, exists := c.Get("APIKey"); exists {
+		apiKey := value.(config.APIKeyDefinition)
+		if apiKey.Timezone != "" {
+			timezone = apiKey.Timezone
+		}
+		if apiKey.Locale != "" {
+			locale = apiKey.Locale
+		}
+	}
+	if header := c.GetHeader("X-Claude-Timezone"); header != "" {
+		// 无效的时区会被 claude.ai 忽略，这里提前校验并保留原值
+		if _, err := time.LoadLocation(header); err != nil {
+			logger.Warn(fmt.Sprintf("Ignoring invalid timezone %s: %v", header, err))
+		} else {
+			timezone = header
+		}
+	}
+	if header := c.GetHeader("X-Claude-Locale"); header != "" {
+		locale = header
+	}
+	return timezone, locale
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"claude2api/model"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OllamaChatHandler handles the Ollama-compatible /api/chat endpoint
+func OllamaChatHandler(c *gin.Context) {
+	var req model.OllamaChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	chatReq, err := req.ToChatRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	serveOllamaRequest(c, chatReq, false)
+}
+
+// OllamaGenerateHandler handles the Ollama-compatible /api/generate endpoint
+func OllamaGenerateHandler(c *gin.Context) {
+	var req model.OllamaGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	chatReq, err := req.ToChatRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	serveOllamaRequest(c, chatReq, true)
+}
+
+func serveOllamaRequest(c *gin.Context, chatReq *model.ChatCompletionRequest, generate bool) {
+	opts := buildChatOptions(chatReq)
+	processor := processMessages(opts, chatReq.Messages)
+	opts.NewWriter = func() model.CompletionWriter {
+		return model.NewOllamaWriter(c, opts.Stream, opts.Alias, generate)
+	}
+	serveRequest(c, opts, processor)
+}
+
+// OllamaTagsHandler lists the available models in Ollama's /api/tags format
+func OllamaTagsHandler(c *gin.Context) {
+	modifiedAt := time.Now().UTC().Format(time.RFC3339)
+	models := []gin.H{}
+	for _, name := range availableModels() {
+		models = append(models, gin.H{
+			"name":        name,
+			"model":       name,
+			"modified_at": modifiedAt,
+			"size":        0,
+			"digest":      "",
+			"details": gin.H{
+				"format":             "",
+				"family":             "claude",
+				"families":           []string{"claude"},
+				"parameter_size":     "",
+				"quantization_level": "",
+			},
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"models": models})
+}
+
+// OllamaVersionHandler reports an Ollama version so clients accept the server
+func OllamaVersionHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"version": "0.6.0"})
+}
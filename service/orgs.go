@@ -0,0 +1,101 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/core"
+	"claude2api/logger"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExpandSessions replaces every session configured with the * organization
+// by one pool entry per organization of its account. Sessions that cannot be
+// listed are kept and retried on the next session check.
+func ExpandSessions() {
+	// 按 ID 替换会话，展开其它会话不影响快照中的条目
+	sessions, _ := config.ConfigInstance.SessionPool()
+	for _, session := range sessions {
+		if session.Org != "*" {
+			continue
+		}
+		proxy, err := config.ConfigInstance.ProxyForSession(session)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to expand session %s: %v", session.Label(), err))
+			continue
+		}
+		client, _ := core.GetClient(session.SessionKey, proxy)
+		orgs, err := client.GetOrganizations()
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to list organizations of session %s: %v", session.Label(), err))
+			continue
+		}
+		if len(orgs) == 0 {
+			logger.Error(fmt.Sprintf("Session %s has no organizations", session.Label()))
+			continue
+		}
+		expanded := make([]config.SessionInfo, 0, len(orgs))
+		for _, org := range orgs {
+			expanded = append(expanded, config.SessionInfo{
+				SessionKey: session.SessionKey,
+				OrgID:      org.UUID,
+				Proxy:      session.Proxy,
+				Org:        org.UUID,
+			})
+		}
+		config.ConfigInstance.ExpandSession(session.ID(), expanded)
+		logger.Info(fmt.Sprintf("Expanded session %s into %d organizations: %s", session.Label(), len(orgs), core.DescribeOrganizations(orgs)))
+	}
+}
+
+// OrganizationsHandler lists the organizations available to the session at
+// the :index of the pool and marks the one the entry uses
+func OrganizationsHandler(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid session index"})
+		return
+	}
+	session, err := config.ConfigInstance.GetSessionForModel(index)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	proxy, err := config.ConfigInstance.ProxyForSession(session)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: err.Error()})
+		return
+	}
+	client, _ := core.GetClient(session.SessionKey, proxy)
+	orgs, err := client.GetOrganizations()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, ErrorResponse{Error: fmt.Sprintf("Failed to list organizations: %v", err)})
+		return
+	}
+	selected := session.OrgID
+	selectionError := ""
+	if selected == "" {
+		if org, err := core.SelectOrganization(orgs, session.Org); err != nil {
+			selectionError = err.Error()
+		} else {
+			selected = org.UUID
+		}
+	}
+	data := []gin.H{}
+	for _, org := range orgs {
+		data = append(data, gin.H{
+			"uuid":            org.UUID,
+			"name":            org.Name,
+			"rate_limit_tier": org.RateLimitTier,
+			"capabilities":    org.Capabilities,
+			"selected":        org.UUID == selected,
+		})
+	}
+	response := gin.H{"session": session.Label(), "org": session.Org, "data": data}
+	if selectionError != "" {
+		response["error"] = selectionError
+	}
+	c.JSON(http.StatusOK, response)
+}
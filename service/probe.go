@@ -0,0 +1,153 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/core"
+	"claude2api/logger"
+	"claude2api/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionCheck 为一个会话的检查结果
+type SessionCheck struct {
+	Session config.SessionInfo
+	Record  store.SessionRecord
+}
+
+// ProbeSessions checks every configured session against claude.ai and saves
+// its organization details, or the reason it is unusable
+func ProbeSessions() []SessionCheck {
+	ExpandSessions()
+	sessions, _ := config.ConfigInstance.SessionPool()
+	checks := make([]SessionCheck, len(sessions))
+	var wg sync.WaitGroup
+	for i, session := range sessions {
+		wg.Add(1)
+		go func(i int, session config.SessionInfo) {
+			defer wg.Done()
+			checks[i] = SessionCheck{Session: session, Record: probeSession(session)}
+		}(i, session)
+	}
+	wg.Wait()
+	return checks
+}
+
+func probeSession(session config.SessionInfo) store.SessionRecord {
+	record, _, _ := store.Default.GetSession(session.Fingerprint())
+	record.Session = session.Fingerprint()
+	record.CheckedAt = time.Now()
+	record.Expired = false
+	record.LastError = ""
+
+	org, err := probeOrganization(session)
+	if err != nil {
+		// 只有 claude.ai 明确拒绝时才标记为过期，网络错误下次检查时重试
+		record.Expired = errors.Is(err, core.ErrSessionExpired)
+		record.LastError = err.Error()
+		logger.Warn(fmt.Sprintf("Session %s check failed: %v", session.Label(), err))
+	} else {
+		record.OrgID = org.UUID
+		record.OrgName = org.Name
+		record.RateLimitTier = org.RateLimitTier
+		record.Capabilities = org.Capabilities
+		record.UpdatedAt = record.CheckedAt
+		if session.OrgID == "" {
+			config.ConfigInstance.SetSessionOrgID(session.ID(), org.UUID)
+		}
+	}
+	if err := store.Default.SaveSession(record); err != nil {
+		logger.Error(fmt.Sprintf("Failed to save session %s: %v", session.Label(), err))
+	}
+	return record
+}
+
+// probeOrganization 返回会话使用的组织，配置了组织 ID 时校验其存在
+func probeOrganization(session config.SessionInfo) (*core.Organization, error) {
+	proxy, err := config.ConfigInstance.ProxyForSession(session)
+	if err != nil {
+		return nil, err
+	}
+	client, _ := core.GetClient(session.SessionKey, proxy)
+	orgs, err := client.GetOrganizations()
+	if err != nil {
+		return nil, err
+	}
+	if session.OrgID == "" {
+		return core.SelectOrganization(orgs, session.Org)
+	}
+	return core.SelectOrganization(orgs, session.OrgID)
+}
+
+// sessionExpired 判断会话在最近一次检查中是否被 claude.ai 拒绝
+func sessionExpired(session config.SessionInfo) bool {
+	record, ok, err := store.Default.GetSession(session.Fingerprint())
+	return err == nil && ok && record.Expired
+}
+
+// StartSessionProbe checks the sessions once and then every
+// SESSION_CHECK_INTERVAL. With REQUIRE_VALID_SESSION it returns an error
+// when sessions are configured but none of them is usable. The periodic
+// checks stop when ctx is done.
+func StartSessionProbe(ctx context.Context) error {
+	if sessions, _ := config.ConfigInstance.SessionPool(); len(sessions) == 0 {
+		return nil
+	}
+	checks := ProbeSessions()
+	valid := 0
+	for _, check := range checks {
+		if record := check.Record; record.Valid() {
+			valid++
+			logger.Info(fmt.Sprintf("Session %s: org %s (%s), tier %s", check.Session.Label(), record.OrgName, record.OrgID, record.RateLimitTier))
+		}
+	}
+	logger.Info(fmt.Sprintf("Session check: %d of %d sessions valid", valid, len(checks)))
+	if valid == 0 && config.ConfigInstance.RequireValidSession {
+		return errors.New("no valid session")
+	}
+
+	interval := config.ConfigInstance.SessionCheckInterval
+	if interval > 0 {
+		runPeriodically(ctx, interval, func() {
+			ProbeSessions()
+		})
+	}
+	return nil
+}
+
+// SessionsHandler lists the configured sessions with the result of their last check
+func SessionsHandler(c *gin.Context) {
+	sessions := []gin.H{}
+	pool, _ := config.ConfigInstance.SessionPool()
+	for i, session := range pool {
+		record, _, _ := store.Default.GetSession(session.Fingerprint())
+		entry := gin.H{
+			"index":           i,
+			"session":         session.Label(),
+			"org_id":          session.OrgID,
+			"org_name":        record.OrgName,
+			"rate_limit_tier": record.RateLimitTier,
+			"capabilities":    record.Capabilities,
+			"valid":           record.Valid(),
+			"expired":         record.Expired,
+			"error":           record.LastError,
+		}
+		if !record.CheckedAt.IsZero() {
+			entry["checked_at"] = record.CheckedAt
+		}
+		sessions = append(sessions, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+// CheckSessionsHandler checks every session now and returns the results
+func CheckSessionsHandler(c *gin.Context) {
+	ProbeSessions()
+	SessionsHandler(c)
+}
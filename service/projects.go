@@ -0,0 +1,85 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/core"
+	"claude2api/logger"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveProject 返回对话所属项目的 UUID，project 为 PROJECTS 中的项目名，
+// 未配置的值视为项目 UUID 直接使用
+func resolveProject(client *core.Client, project string) (string, error) {
+	if project == "" {
+		return "", nil
+	}
+	definition, ok := config.ConfigInstance.GetProject(project)
+	if !ok {
+		return project, nil
+	}
+	return client.EnsureProject(projectSpec(definition))
+}
+
+func projectSpec(definition config.ProjectDefinition) core.ProjectSpec {
+	spec := core.ProjectSpec{
+		Name:         definition.Name,
+		Description:  definition.Description,
+		Instructions: definition.Instructions,
+	}
+	for _, file := range definition.Files {
+		spec.Docs = append(spec.Docs, core.ProjectDoc{FileName: file.FileName, Content: file.Content})
+	}
+	return spec
+}
+
+// ProjectsHandler lists the configured projects
+func ProjectsHandler(c *gin.Context) {
+	projects := []gin.H{}
+	for _, project := range config.ConfigInstance.Projects {
+		docs := []string{}
+		for _, file := range project.Files {
+			docs = append(docs, file.FileName)
+		}
+		projects = append(projects, gin.H{
+			"name":        project.Name,
+			"description": project.Description,
+			"docs":        docs,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": projects})
+}
+
+// SyncProjectsHandler creates or updates the configured projects in every
+// session's account and returns their UUIDs per session
+func SyncProjectsHandler(c *gin.Context) {
+	results := []gin.H{}
+	sessions, _ := config.ConfigInstance.SessionPool()
+	for _, session := range sessions {
+		result := gin.H{"session": session.Label()}
+		client, err := sessionClient(session)
+		if err != nil {
+			result["error"] = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		projects := gin.H{}
+		for _, definition := range config.ConfigInstance.Projects {
+			// 同步时总是重新查找项目并更新说明和文档
+			client.ForgetProject(definition.Name)
+			projectUUID, err := client.EnsureProject(projectSpec(definition))
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to sync project %s for session %s: %v", definition.Name, session.Label(), err))
+				projects[definition.Name] = gin.H{"error": err.Error()}
+				continue
+			}
+			projects[definition.Name] = gin.H{"uuid": projectUUID}
+		}
+		result["projects"] = projects
+		results = append(results, result)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
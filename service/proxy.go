@@ -0,0 +1,23 @@
+package service
+
+import (
+	"claude2api/config"
+	"context"
+)
+
+// StartProxyHealthCheck probes the proxy pool once at startup and then every
+// PROXY_CHECK_INTERVAL until ctx is done
+func StartProxyHealthCheck(ctx context.Context) {
+	pool := config.ConfigInstance.ProxyPool
+	interval := config.ConfigInstance.ProxyCheckInterval
+	if pool == nil || pool.Len() == 0 || interval <= 0 {
+		return
+	}
+	// 首次检查在后台进行，不阻塞启动
+	backgroundTasks.Add(1)
+	go func() {
+		defer backgroundTasks.Done()
+		pool.CheckAll()
+	}()
+	runPeriodically(ctx, interval, pool.CheckAll)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/logger"
+	"claude2api/model"
+	"claude2api/store"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseStoreTTL 为保存的响应的有效期，过期的响应由存储清理任务删除
+const responseStoreTTL = 24 * time.Hour
+
+// responseOwner 返回请求所属的 API 密钥名称，镜像模式下按会话密钥的指纹区分
+func responseOwner(c *gin.Context) string {
+	if value, exists := c.Get("APIKey"); exists {
+		return value.(config.APIKeyDefinition).Name
+	}
+	session, err := extractSessionFromAuthHeader(c)
+	if err != nil {
+		return "mirror"
+	}
+	return "mirror/" + config.SessionInfo{SessionKey: session.SessionKey}.Fingerprint()
+}
+
+// loadResponse 返回请求的 API 密钥保存的未过期响应，其它密钥的响应视为不存在
+func loadResponse(c *gin.Context, id string) (store.ResponseRecord, bool) {
+	record, ok, err := store.Default.GetResponse(id)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load response %s: %v", id, err))
+		return record, false
+	}
+	if !ok || record.APIKey != responseOwner(c) || time.Since(record.CreatedAt) > responseStoreTTL {
+		return record, false
+	}
+	return record, true
+}
+
+// ResponsesHandler handles the OpenAI Responses API endpoint
+func ResponsesHandler(c *gin.Context) {
+	req := model.ResponsesRequest{
+		Stream: false,
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	// 从之前的响应中恢复对话历史
+	history := []map[string]interface{}{}
+	if req.PreviousResponseID != "" {
+		previous, ok := loadResponse(c, req.PreviousResponseID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error: fmt.Sprintf("Previous response with id '%s' not found", req.PreviousResponseID),
+			})
+			return
+		}
+		history = previous.Messages
+	}
+	chatReq, err := req.ToChatRequest(history)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	opts := buildChatOptions(chatReq)
+	processor := processMessages(opts, chatReq.Messages)
+	var writer *model.ResponsesWriter
+	opts.NewWriter = func() model.CompletionWriter {
+		writer = model.NewResponsesWriter(c, opts.Stream, opts.Alias, req.PreviousResponseID)
+		return writer
+	}
+	serveRequest(c, opts, processor)
+
+	if writer == nil || !req.ShouldStore() {
+		return
+	}
+	if response := writer.Response(); response.Status == "completed" || response.Status == "incomplete" {
+		// instructions 不会带入后续响应，只保存历史和本次的输入输出
+		input, _ := req.InputMessages()
+		messages := append(append([]map[string]interface{}{}, history...), input...)
+		messages = append(messages, map[string]interface{}{"role": "assistant", "content": writer.OutputText()})
+		data, err := json.Marshal(response)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to encode response %s: %v", response.ID, err))
+			return
+		}
+		if err := store.Default.SaveResponse(store.ResponseRecord{
+			ID:        response.ID,
+			APIKey:    responseOwner(c),
+			Response:  data,
+			Messages:  messages,
+			CreatedAt: time.Now(),
+		}); err != nil {
+			logger.Error(fmt.Sprintf("Failed to store response %s: %v", response.ID, err))
+		}
+	}
+}
+
+// GetResponseHandler returns a stored response by id
+func GetResponseHandler(c *gin.Context) {
+	stored, ok := loadResponse(c, c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: fmt.Sprintf("Response with id '%s' not found", c.Param("id")),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", stored.Response)
+}
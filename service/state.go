@@ -0,0 +1,157 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/core"
+	"claude2api/logger"
+	"claude2api/store"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RestoreSessions fills in the org IDs saved by previous runs, so sessions do
+// not have to query their organization again after a restart
+func RestoreSessions() {
+	records, err := store.Default.Sessions()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load sessions from store: %v", err))
+		return
+	}
+	saved := make(map[string]string, len(records))
+	for _, record := range records {
+		saved[record.Session] = record.OrgID
+	}
+	sessions, _ := config.ConfigInstance.SessionPool()
+	for _, session := range sessions {
+		if orgID := saved[session.Fingerprint()]; session.OrgID == "" && orgID != "" {
+			config.ConfigInstance.SetSessionOrgID(session.ID(), orgID)
+		}
+	}
+}
+
+// resolveOrgID 返回会话的组织 ID，优先使用存储中的记录，否则查询后保存
+func resolveOrgID(client *core.Client, session config.SessionInfo) (string, error) {
+	if session.OrgID != "" {
+		return session.OrgID, nil
+	}
+	if record, ok, err := store.Default.GetSession(session.Fingerprint()); err == nil && ok && record.OrgID != "" {
+		config.ConfigInstance.SetSessionOrgID(session.ID(), record.OrgID)
+		return record.OrgID, nil
+	}
+	orgs, err := client.GetOrganizations()
+	if err != nil {
+		return "", err
+	}
+	org, err := core.SelectOrganization(orgs, session.Org)
+	if err != nil {
+		return "", err
+	}
+	orgID := org.UUID
+	config.ConfigInstance.SetSessionOrgID(session.ID(), orgID)
+	record, _, _ := store.Default.GetSession(session.Fingerprint())
+	record.Session = session.Fingerprint()
+	record.OrgID = orgID
+	record.OrgName = org.Name
+	record.UpdatedAt = time.Now()
+	if err := store.Default.SaveSession(record); err != nil {
+		logger.Error(fmt.Sprintf("Failed to save session %s: %v", session.Label(), err))
+	}
+	return orgID, nil
+}
+
+// knownSessionTTL 为镜像会话最后一次创建对话后保留的最短时间
+const knownSessionTTL = 24 * time.Hour
+
+// knownSession 为创建过对话的会话及其最后一次创建对话的时间
+type knownSession struct {
+	session  config.SessionInfo
+	lastSeen time.Time
+}
+
+// knownSessions 按指纹记录创建过对话的会话，清理任务据此重建镜像会话的客户端。
+// 只保存在内存中，重启后或长时间未使用后，镜像会话的对话要等该会话再次请求后才能清理
+var knownSessions = struct {
+	sync.Mutex
+	entries   map[string]knownSession
+	lastEvict time.Time
+}{entries: make(map[string]knownSession)}
+
+// rememberSession 记录会话，并每分钟最多一次移除超过保留时间的会话。
+// 保留时间不短于 SWEEP_MAX_AGE 加一次清理间隔，保证清理任务能处理它们的对话
+func rememberSession(session config.SessionInfo) {
+	knownSessions.Lock()
+	defer knownSessions.Unlock()
+	now := time.Now()
+	if now.Sub(knownSessions.lastEvict) >= time.Minute {
+		knownSessions.lastEvict = now
+		ttl := max(knownSessionTTL, config.ConfigInstance.SweepMaxAge+config.ConfigInstance.SweepInterval)
+		for fingerprint, known := range knownSessions.entries {
+			if now.Sub(known.lastSeen) > ttl {
+				delete(knownSessions.entries, fingerprint)
+			}
+		}
+	}
+	knownSessions.entries[session.Fingerprint()] = knownSession{session: session, lastSeen: now}
+}
+
+// lookupSession 按指纹返回记录过的会话
+func lookupSession(fingerprint string) (config.SessionInfo, bool) {
+	knownSessions.Lock()
+	defer knownSessions.Unlock()
+	known, ok := knownSessions.entries[fingerprint]
+	return known.session, ok
+}
+
+// trackConversation 记录新建的对话，删除成功前由清理任务负责
+func trackConversation(session config.SessionInfo, conversationID string) {
+	rememberSession(session)
+	if err := store.Default.SaveConversation(store.ConversationRecord{
+		UUID:      conversationID,
+		Session:   session.Fingerprint(),
+		OrgID:     session.OrgID,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		logger.Error(fmt.Sprintf("Failed to record conversation %s: %v", conversationID, err))
+	}
+}
+
+// forgetConversation 将已删除或需要保留的对话移出记录
+func forgetConversation(conversationID string) {
+	if err := store.Default.DeleteConversation(conversationID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to remove conversation %s from ledger: %v", conversationID, err))
+	}
+}
+
+// uploadFiles 上传图片，启用 UPLOAD_CACHE_TTL 时相同内容在有效期内复用已上传的文件
+func uploadFiles(client *core.Client, fileData []string) ([]string, error) {
+	ttl := config.ConfigInstance.UploadCacheTTL
+	if ttl <= 0 {
+		return client.UploadFile(fileData)
+	}
+	fileUUIDs := []string{}
+	for _, data := range fileData {
+		if data == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(client.OrgID() + "\n" + data))
+		hash := hex.EncodeToString(sum[:])
+		if record, ok, err := store.Default.GetUpload(hash); err == nil && ok && time.Since(record.CreatedAt) < ttl {
+			fileUUIDs = append(fileUUIDs, record.FileUUID)
+			continue
+		}
+		uploaded, err := client.UploadFile([]string{data})
+		if err != nil {
+			return nil, err
+		}
+		fileUUIDs = append(fileUUIDs, uploaded...)
+		if len(uploaded) == 1 {
+			if err := store.Default.SaveUpload(store.UploadRecord{Hash: hash, FileUUID: uploaded[0], CreatedAt: time.Now()}); err != nil {
+				logger.Error(fmt.Sprintf("Failed to cache upload: %v", err))
+			}
+		}
+	}
+	return fileUUIDs, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/core"
+	"claude2api/logger"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resolveStyle 返回回复使用的风格，name 为 STYLES 中的风格名时使用自定义提示，
+// 否则在账号的风格列表中查找，找不到时使用 Normal
+func resolveStyle(client *core.Client, name string) core.Style {
+	if name == "" || strings.EqualFold(name, core.DefaultStyle.Name) || strings.EqualFold(name, core.DefaultStyle.Key) {
+		return core.DefaultStyle
+	}
+	if definition, ok := config.ConfigInstance.GetStyle(name); ok {
+		return core.NewCustomStyle(definition.Name, definition.Prompt, definition.Summary)
+	}
+	style, err := client.FindStyle(name)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to resolve style %s: %v, using default", name, err))
+		return core.DefaultStyle
+	}
+	return style
+}
+
+// StylesHandler lists the configured custom styles and the styles available
+// to a session's account. The session is chosen by the ?session index, or
+// taken from the Authorization header in mirror mode.
+func StylesHandler(c *gin.Context) {
+	styles := []core.Style{}
+	for _, definition := range config.ConfigInstance.Styles {
+		styles = append(styles, core.NewCustomStyle(definition.Name, definition.Prompt, definition.Summary))
+	}
+
+	var session config.SessionInfo
+	var err error
+	if useMirror, exist := c.Get("UseMirrorApi"); exist && useMirror.(bool) {
+		session, err = extractSessionFromAuthHeader(c)
+	} else {
+		index, _ := strconv.Atoi(c.DefaultQuery("session", "0"))
+		session, err = config.ConfigInstance.GetSessionForModel(index)
+	}
+	if err != nil {
+		// 没有可用会话时只返回自定义风格
+		c.JSON(http.StatusOK, gin.H{"data": append(styles, core.DefaultStyle)})
+		return
+	}
+
+	client, err := sessionClient(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	accountStyles, err := client.ListStyles()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to list styles: %v", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list styles: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": append(styles, accountStyles...)})
+}
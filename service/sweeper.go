@@ -0,0 +1,168 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/core"
+	"claude2api/logger"
+	"claude2api/store"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SweepResult 为一个会话的清理结果
+type SweepResult struct {
+	Session string `json:"session"`
+	Deleted int    `json:"deleted"`
+	Failed  int    `json:"failed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// sweepLock 避免定时清理和手动清理同时进行
+var sweepLock sync.Mutex
+
+// StartConversationSweeper periodically deletes conversations left behind by
+// failed cleanups or crashes. It only runs when CHAT_DELETE is enabled and
+// stops when ctx is done.
+func StartConversationSweeper(ctx context.Context) {
+	interval := config.ConfigInstance.SweepInterval
+	if !config.ConfigInstance.ChatDelete || interval <= 0 {
+		return
+	}
+	runPeriodically(ctx, interval, func() {
+		for _, result := range SweepConversations(config.ConfigInstance.SweepMaxAge) {
+			if result.Deleted > 0 || result.Failed > 0 || result.Error != "" {
+				logger.Info(fmt.Sprintf("Swept session %s: deleted %d, failed %d %s", result.Session, result.Deleted, result.Failed, result.Error))
+			}
+		}
+	})
+}
+
+// SweepConversations deletes the conversations created by this proxy that are
+// older than maxAge, in every configured session and every session in the ledger
+func SweepConversations(maxAge time.Duration) []SweepResult {
+	sweepLock.Lock()
+	defer sweepLock.Unlock()
+	cutoff := time.Now().Add(-maxAge)
+
+	sessions, _ := config.ConfigInstance.SessionPool()
+	// configured 记录配置中的会话，只有这些会话会按名称前缀清理。
+	// 同一会话密钥可能展开为多个组织，因此按会话 ID 的指纹区分
+	configured := make(map[string]bool)
+	for _, session := range sessions {
+		configured[session.Fingerprint()] = true
+	}
+	// 镜像模式的会话不在配置中，只按记录清理
+	records, err := store.Default.Conversations()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load conversation ledger: %v", err))
+	}
+	tracked := make(map[string][]store.ConversationRecord)
+	unknown := make(map[string]int)
+	for _, record := range records {
+		if _, ok := tracked[record.Session]; !ok && !configured[record.Session] {
+			// 记录中只有会话指纹，镜像会话需要本次运行中最近见过才能重建客户端
+			session, ok := lookupSession(record.Session)
+			if !ok {
+				unknown[record.Session]++
+				continue
+			}
+			sessions = append(sessions, session)
+		}
+		tracked[record.Session] = append(tracked[record.Session], record)
+	}
+
+	results := []SweepResult{}
+	for _, session := range sessions {
+		results = append(results, sweepSession(session, tracked[session.Fingerprint()], configured[session.Fingerprint()], cutoff))
+	}
+	for fingerprint, count := range unknown {
+		results = append(results, SweepResult{
+			Session: fingerprint[:12],
+			Error:   fmt.Sprintf("%d conversations kept, session not seen recently", count),
+		})
+	}
+	return results
+}
+
+// sweepSession 删除会话中早于 cutoff 的记录对话，配置了名称前缀时同时删除账号中带前缀的对话
+func sweepSession(session config.SessionInfo, records []store.ConversationRecord, configured bool, cutoff time.Time) SweepResult {
+	result := SweepResult{Session: session.Label()}
+	proxy, err := config.ConfigInstance.ProxyForSession(session)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	client, _ := core.GetClient(session.SessionKey, proxy)
+
+	// 待删除的对话 UUID 及其所属组织
+	candidates := make(map[string]string)
+	for _, record := range records {
+		if record.CreatedAt.Before(cutoff) {
+			orgID := record.OrgID
+			if orgID == "" {
+				orgID = session.OrgID
+			}
+			candidates[record.UUID] = orgID
+		}
+	}
+	if prefix := config.ConfigInstance.ConversationPrefix; prefix != "" && configured {
+		orgClient, err := sessionClient(session)
+		if err != nil {
+			result.Error = err.Error()
+		} else if conversations, err := orgClient.ListConversations(); err != nil {
+			result.Error = fmt.Sprintf("failed to list conversations: %v", err)
+		} else {
+			orgID := orgClient.OrgID()
+			for _, conversation := range conversations {
+				if strings.HasPrefix(conversation.Name, prefix) && conversation.CreatedAt.Before(cutoff) {
+					if _, ok := candidates[conversation.UUID]; !ok {
+						candidates[conversation.UUID] = orgID
+					}
+				}
+			}
+		}
+	}
+
+	for conversationID, orgID := range candidates {
+		client.SetOrgID(orgID)
+		err := client.DeleteConversation(conversationID)
+		if err != nil && !errors.Is(err, core.ErrConversationNotFound) {
+			logger.Error(fmt.Sprintf("Failed to sweep conversation %s of session %s: %v", conversationID, session.Label(), err))
+			result.Failed++
+			continue
+		}
+		forgetConversation(conversationID)
+		if err == nil {
+			result.Deleted++
+		}
+	}
+	return result
+}
+
+// PurgeConversationsHandler deletes leftover conversations on demand. The
+// max_age query parameter overrides SWEEP_MAX_AGE, max_age=0s purges all of them.
+// When CHAT_DELETE is disabled conversations are meant to be kept, so the purge
+// is refused unless force=true is given.
+func PurgeConversationsHandler(c *gin.Context) {
+	if !config.ConfigInstance.ChatDelete && c.Query("force") != "true" {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "CHAT_DELETE is disabled, pass force=true to purge conversations anyway"})
+		return
+	}
+	maxAge := config.ConfigInstance.SweepMaxAge
+	if value := c.Query("max_age"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid max_age: %v", err)})
+			return
+		}
+		maxAge = parsed
+	}
+	c.JSON(http.StatusOK, gin.H{"data": SweepConversations(maxAge)})
+}
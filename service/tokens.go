@@ -0,0 +1,97 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/model"
+	"claude2api/utils"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promptStats 描述请求转换后的提示词规模，以及它会以何种方式发送
+type promptStats struct {
+	Model      string `json:"model"`
+	Tokens     int    `json:"tokens"`
+	Characters int    `json:"characters"`
+	Images     int    `json:"images"`
+	// Mode 为 inline，或超长时使用的处理策略，如 attachment 表示会作为 context.txt 附件发送
+	Mode        string `json:"mode"`
+	Attachments int    `json:"attachments"`
+	// InlineTokens 为处理后仍在提示词中的 token 数
+	InlineTokens         int `json:"inline_tokens"`
+	MaxChatHistoryLength int `json:"max_chat_history_length"`
+	MaxTokens            int `json:"max_tokens,omitempty"`
+}
+
+// countPrompt 按实际发送时的方式处理消息并统计提示词
+func countPrompt(req *model.ChatCompletionRequest) promptStats {
+	opts := buildChatOptions(req)
+	processor := processMessages(opts, req.Messages)
+
+	prompt := processor.Prompt.String()
+	stats := promptStats{
+		Model:                opts.Alias,
+		Tokens:               utils.EstimateTokens(prompt),
+		Characters:           utf8.RuneCountInString(prompt),
+		Images:               len(processor.ImgDataList),
+		Mode:                 "inline",
+		MaxChatHistoryLength: config.ConfigInstance.MaxChatHistoryLength,
+		MaxTokens:            config.ConfigInstance.ContextMaxTokens,
+	}
+	if processor.Overflows() {
+		files, strategy := processor.FitContext(opts.ContextStrategy)
+		stats.Mode = strategy
+		stats.Attachments = len(files)
+	}
+	stats.InlineTokens = utils.EstimateTokens(processor.Prompt.String())
+	return stats
+}
+
+// CountTokensHandler estimates the prompt size of a chat completions request
+func CountTokensHandler(c *gin.Context) {
+	var req model.ChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	if len(req.Messages) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "No messages provided",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, countPrompt(&req))
+}
+
+// AnthropicCountTokensHandler handles the Anthropic-compatible /v1/messages/count_tokens endpoint
+func AnthropicCountTokensHandler(c *gin.Context) {
+	var req model.AnthropicMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	chatReq, err := req.ToChatRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	stats := countPrompt(chatReq)
+	c.JSON(http.StatusOK, gin.H{
+		"input_tokens":            stats.Tokens,
+		"characters":              stats.Characters,
+		"images":                  stats.Images,
+		"mode":                    stats.Mode,
+		"attachments":             stats.Attachments,
+		"inline_tokens":           stats.InlineTokens,
+		"max_chat_history_length": stats.MaxChatHistoryLength,
+	})
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"claude2api/config"
+	"claude2api/core"
+	"claude2api/logger"
+	"claude2api/model"
+	"claude2api/store"
+	"claude2api/utils"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordUsage 保存一次请求的用量，result 为空表示请求失败，session 为空表示没有可用的会话
+func recordUsage(c *gin.Context, opts chatOptions, session config.SessionInfo, processor *utils.ChatRequestProcessor, result *core.CompletionResult, retries int, start time.Time) {
+	record := store.UsageRecord{
+		Time:   start,
+		APIKey: "mirror",
+		Model:  opts.Alias,
+		// 按完整的提示词估算，超出上下文时放入附件的内容同样计入
+		PromptTokens: utils.EstimateTokens(processor.RootPrompt.String()),
+		Retries:      max(retries, 0),
+		Error:        result == nil,
+		Latency:      time.Since(start),
+	}
+	if session.SessionKey != "" {
+		record.Session = session.Label()
+	}
+	if value, exists := c.Get("APIKey"); exists {
+		record.APIKey = value.(config.APIKeyDefinition).Name
+	}
+	if result != nil {
+		record.CompletionTokens = utils.EstimateTokens(result.Thinking) + utils.EstimateTokens(result.Text)
+	}
+	if err := store.Default.AddUsage(record); err != nil {
+		logger.Error(fmt.Sprintf("Failed to record usage: %v", err))
+	}
+}
+
+// StartStorePruner deletes the usage records older than USAGE_RETENTION, the
+// cached uploads older than UPLOAD_CACHE_TTL and the expired stored responses
+// once at startup and then every hour until ctx is done
+func StartStorePruner(ctx context.Context) {
+	prune := func() {
+		if retention := config.ConfigInstance.UsageRetention; retention > 0 {
+			deleted, err := store.Default.PruneUsage(time.Now().Add(-retention))
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to prune usage records: %v", err))
+			} else if deleted > 0 {
+				logger.Info(fmt.Sprintf("Pruned %d usage records older than %s", deleted, retention))
+			}
+		}
+		// 过期的上传缓存不会再被复用，UPLOAD_CACHE_TTL 为 0 时删除全部缓存
+		ttl := max(config.ConfigInstance.UploadCacheTTL, 0)
+		deleted, err := store.Default.PruneUploads(time.Now().Add(-ttl))
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to prune cached uploads: %v", err))
+		} else if deleted > 0 {
+			logger.Info(fmt.Sprintf("Pruned %d cached uploads older than %s", deleted, ttl))
+		}
+		deleted, err = store.Default.PruneResponses(time.Now().Add(-responseStoreTTL))
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to prune stored responses: %v", err))
+		} else if deleted > 0 {
+			logger.Info(fmt.Sprintf("Pruned %d stored responses older than %s", deleted, responseStoreTTL))
+		}
+	}
+	prune()
+	runPeriodically(ctx, time.Hour, prune)
+}
+
+// UsageSummary 为一组请求的汇总用量，分组字段按 group_by 填写
+type UsageSummary struct {
+	Day     string `json:"day,omitempty"`
+	APIKey  string `json:"api_key,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Session string `json:"session,omitempty"`
+	model.Usage
+	Requests     int   `json:"requests"`
+	Errors       int   `json:"errors"`
+	Retries      int   `json:"retries"`
+	AvgLatencyMs int64 `json:"avg_latency_ms"`
+
+	totalLatency time.Duration
+}
+
+// usageGroups 为支持的分组字段
+var usageGroups = map[string]bool{"day": true, "key": true, "model": true, "session": true}
+
+// parseUsageTime 解析 YYYY-MM-DD 或 RFC3339 时间，日期按 UTC 计算，endOfDay 时取次日零点
+func parseUsageTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// summarizeUsage 按查询参数读取并汇总用量
+func summarizeUsage(c *gin.Context) ([]string, []*UsageSummary, error) {
+	groups := []string{}
+	for _, group := range strings.Split(c.DefaultQuery("group_by", "day"), ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		if !usageGroups[group] {
+			return nil, nil, fmt.Errorf("unsupported group_by %q, use day, key, model or session", group)
+		}
+		groups = append(groups, group)
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	var err error
+	if value := c.Query("to"); value != "" {
+		if to, err = parseUsageTime(value, true); err != nil {
+			return nil, nil, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if value := c.Query("from"); value != "" {
+		if from, err = parseUsageTime(value, false); err != nil {
+			return nil, nil, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	records, err := store.Default.Usage(from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summaries := []*UsageSummary{}
+	index := make(map[UsageSummary]*UsageSummary)
+	for _, record := range records {
+		// key 只填写分组字段，相同分组的记录汇总到同一行
+		var key UsageSummary
+		for _, group := range groups {
+			switch group {
+			case "day":
+				key.Day = record.Time.UTC().Format("2006-01-02")
+			case "key":
+				key.APIKey = record.APIKey
+			case "model":
+				key.Model = record.Model
+			case "session":
+				key.Session = record.Session
+			}
+		}
+		summary, ok := index[key]
+		if !ok {
+			summary = &UsageSummary{Day: key.Day, APIKey: key.APIKey, Model: key.Model, Session: key.Session}
+			index[key] = summary
+			summaries = append(summaries, summary)
+		}
+		summary.Requests++
+		summary.PromptTokens += record.PromptTokens
+		summary.CompletionTokens += record.CompletionTokens
+		summary.TotalTokens += record.PromptTokens + record.CompletionTokens
+		summary.Retries += record.Retries
+		summary.totalLatency += record.Latency
+		if record.Error {
+			summary.Errors++
+		}
+	}
+	for _, summary := range summaries {
+		summary.AvgLatencyMs = (summary.totalLatency / time.Duration(summary.Requests)).Milliseconds()
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.APIKey != b.APIKey {
+			return a.APIKey < b.APIKey
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Session < b.Session
+	})
+	return groups, summaries, nil
+}
+
+// UsageHandler returns the usage aggregated by the group_by query parameter
+// (day, key, model, session, comma separated) between from and to
+func UsageHandler(c *gin.Context) {
+	_, summaries, err := summarizeUsage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": summaries})
+}
+
+// UsageExportHandler returns the same report as UsageHandler as a CSV file
+func UsageExportHandler(c *gin.Context) {
+	groups, summaries, err := summarizeUsage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename=usage.csv")
+	writer := csv.NewWriter(c.Writer)
+	header := append([]string{}, groups...)
+	header = append(header, "requests", "prompt_tokens", "completion_tokens", "total_tokens", "errors", "retries", "avg_latency_ms")
+	writer.Write(header)
+	for _, summary := range summaries {
+		row := []string{}
+		for _, group := range groups {
+			switch group {
+			case "day":
+				row = append(row, summary.Day)
+			case "key":
+				row = append(row, summary.APIKey)
+			case "model":
+				row = append(row, summary.Model)
+			case "session":
+				row = append(row, summary.Session)
+			}
+		}
+		row = append(row,
+			strconv.Itoa(summary.Requests),
+			strconv.Itoa(summary.PromptTokens),
+			strconv.Itoa(summary.CompletionTokens),
+			strconv.Itoa(summary.TotalTokens),
+			strconv.Itoa(summary.Errors),
+			strconv.Itoa(summary.Retries),
+			strconv.FormatInt(summary.AvgLatencyMs, 10),
+		)
+		writer.Write(row)
+	}
+	writer.Flush()
+}
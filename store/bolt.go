@@ -0,0 +1,253 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sessionsBucket      = []byte("sessions")
+	usageBucket         = []byte("usage")
+	conversationsBucket = []byte("conversations")
+	uploadsBucket       = []byte("uploads")
+	responsesBucket     = []byte("responses")
+)
+
+// BoltStore persists the state in a single bbolt database file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens or creates the database at path
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, usageBucket, conversationsBucket, uploadsBucket, responsesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) put(bucket []byte, key []byte, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(key, data)
+	})
+}
+
+func (s *BoltStore) get(bucket []byte, key []byte, value interface{}) (bool, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// bbolt 返回的切片只在事务内有效
+		if v := tx.Bucket(bucket).Get(key); v != nil {
+			data = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, value)
+}
+
+// each 依次解析 bucket 中的所有值
+func (s *BoltStore) each(bucket []byte, fn func(data []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, v []byte) error {
+			return fn(v)
+		})
+	})
+}
+
+// prune 删除 bucket 中 expired 返回 true 的值，返回删除的数量。
+// 遍历时不能修改 bucket，因此先收集过期的键再删除
+func (s *BoltStore) prune(bucket []byte, expired func(data []byte) (bool, error)) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		keys := [][]byte{}
+		err := b.ForEach(func(k, v []byte) error {
+			ok, err := expired(v)
+			if ok {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *BoltStore) SaveSession(record SessionRecord) error {
+	return s.put(sessionsBucket, []byte(record.Session), record)
+}
+
+func (s *BoltStore) GetSession(session string) (SessionRecord, bool, error) {
+	var record SessionRecord
+	ok, err := s.get(sessionsBucket, []byte(session), &record)
+	return record, ok, err
+}
+
+func (s *BoltStore) Sessions() ([]SessionRecord, error) {
+	records := []SessionRecord{}
+	err := s.each(sessionsBucket, func(data []byte) error {
+		var record SessionRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// usageKey 以纳秒时间戳开头，按键排序即按时间排序，序号避免同一时刻的记录冲突
+func usageKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	// 早于 1970 年的时间（如零值）视为最早的记录
+	if t.Unix() >= 0 {
+		binary.BigEndian.PutUint64(key[:8], uint64(t.UnixNano()))
+	}
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+func (s *BoltStore) AddUsage(record UsageRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usageBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(usageKey(record.Time, seq), data)
+	})
+}
+
+func (s *BoltStore) Usage(from time.Time, to time.Time) ([]UsageRecord, error) {
+	records := []UsageRecord{}
+	end := usageKey(to, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(usageBucket).Cursor()
+		for k, v := cursor.Seek(usageKey(from, 0)); k != nil && string(k) < string(end); k, v = cursor.Next() {
+			var record UsageRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (s *BoltStore) PruneUsage(before time.Time) (int, error) {
+	deleted := 0
+	end := usageKey(before, 0)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(usageBucket).Cursor()
+		// 删除当前记录后游标指向下一条，因此重新读取当前位置
+		for k, _ := cursor.First(); k != nil && string(k) < string(end); k, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *BoltStore) SaveConversation(record ConversationRecord) error {
+	return s.put(conversationsBucket, []byte(record.UUID), record)
+}
+
+func (s *BoltStore) DeleteConversation(conversationID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(conversationsBucket).Delete([]byte(conversationID))
+	})
+}
+
+func (s *BoltStore) Conversations() ([]ConversationRecord, error) {
+	records := []ConversationRecord{}
+	err := s.each(conversationsBucket, func(data []byte) error {
+		var record ConversationRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+func (s *BoltStore) SaveUpload(record UploadRecord) error {
+	return s.put(uploadsBucket, []byte(record.Hash), record)
+}
+
+func (s *BoltStore) GetUpload(hash string) (UploadRecord, bool, error) {
+	var record UploadRecord
+	ok, err := s.get(uploadsBucket, []byte(hash), &record)
+	return record, ok, err
+}
+
+func (s *BoltStore) PruneUploads(before time.Time) (int, error) {
+	// 上传缓存按哈希存储，需要逐条检查创建时间
+	return s.prune(uploadsBucket, func(data []byte) (bool, error) {
+		var record UploadRecord
+		err := json.Unmarshal(data, &record)
+		return err == nil && record.CreatedAt.Before(before), err
+	})
+}
+
+func (s *BoltStore) SaveResponse(record ResponseRecord) error {
+	return s.put(responsesBucket, []byte(record.ID), record)
+}
+
+func (s *BoltStore) GetResponse(id string) (ResponseRecord, bool, error) {
+	var record ResponseRecord
+	ok, err := s.get(responsesBucket, []byte(id), &record)
+	return record, ok, err
+}
+
+func (s *BoltStore) PruneResponses(before time.Time) (int, error) {
+	return s.prune(responsesBucket, func(data []byte) (bool, error) {
+		var record ResponseRecord
+		err := json.Unmarshal(data, &record)
+		return err == nil && record.CreatedAt.Before(before), err
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
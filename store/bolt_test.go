@@ -0,0 +1,206 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openTestStore 在临时目录中创建 bbolt 存储，测试结束时关闭
+func openTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+	s, err := OpenBoltStore(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// usageModels 按顺序返回用量记录的模型名
+func usageModels(records []UsageRecord) []string {
+	models := []string{}
+	for _, record := range records {
+		models = append(models, record.Model)
+	}
+	return models
+}
+
+func TestBoltStoreUsageRange(t *testing.T) {
+	s := openTestStore(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []UsageRecord{
+		{Time: base.Add(2 * time.Hour), Model: "c"},
+		{Time: time.Time{}, Model: "zero"},
+		{Time: time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC), Model: "pre-1970"},
+		{Time: base, Model: "a"},
+		{Time: base, Model: "a2"},
+		{Time: base.Add(time.Hour), Model: "b"},
+	}
+	for _, record := range records {
+		if err := s.AddUsage(record); err != nil {
+			t.Fatalf("failed to add usage: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from     time.Time
+		to       time.Time
+		expected []string
+	}{
+		{"from inclusive to exclusive", base, base.Add(2 * time.Hour), []string{"a", "a2", "b"}},
+		{"to excludes equal time", base.Add(time.Hour), base.Add(time.Hour), []string{}},
+		{"last record", base.Add(2 * time.Hour), base.Add(3 * time.Hour), []string{"c"}},
+		// 早于 1970 年的记录按添加顺序排在最前面
+		{"pre-1970 records first", time.Time{}, base.Add(time.Hour), []string{"zero", "pre-1970", "a", "a2"}},
+		{"everything", time.Time{}, base.Add(24 * time.Hour), []string{"zero", "pre-1970", "a", "a2", "b", "c"}},
+		{"empty range", base.Add(24 * time.Hour), base.Add(48 * time.Hour), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := s.Usage(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("failed to read usage: %v", err)
+			}
+			if models := usageModels(usage); !reflect.DeepEqual(models, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, models)
+			}
+		})
+	}
+}
+
+func TestBoltStorePruneUsage(t *testing.T) {
+	s := openTestStore(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, model := range []string{"a", "b", "c"} {
+		if err := s.AddUsage(UsageRecord{Time: base.Add(time.Duration(i) * time.Hour), Model: model}); err != nil {
+			t.Fatalf("failed to add usage: %v", err)
+		}
+	}
+	if err := s.AddUsage(UsageRecord{Model: "zero"}); err != nil {
+		t.Fatalf("failed to add usage: %v", err)
+	}
+
+	deleted, err := s.PruneUsage(base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to prune usage: %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 deleted records, got %d", deleted)
+	}
+	usage, err := s.Usage(time.Time{}, base.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to read usage: %v", err)
+	}
+	if models := usageModels(usage); !reflect.DeepEqual(models, []string{"b", "c"}) {
+		t.Fatalf("expected [b c] to remain, got %v", models)
+	}
+
+	if deleted, err := s.PruneUsage(base); err != nil || deleted != 0 {
+		t.Fatalf("expected nothing to prune, got %d, %v", deleted, err)
+	}
+}
+
+func TestBoltStoreSessionRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	checkedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := SessionRecord{
+		Session:       "fingerprint",
+		OrgID:         "org-1",
+		UpdatedAt:     checkedAt,
+		OrgName:       "Org",
+		RateLimitTier: "default_claude_max_5x",
+		Capabilities:  []string{"chat", "claude_max"},
+		CheckedAt:     checkedAt,
+	}
+	if err := s.SaveSession(record); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	got, ok, err := s.GetSession("fingerprint")
+	if err != nil || !ok {
+		t.Fatalf("expected the session to be found, got %v, %v", ok, err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Fatalf("expected %+v, got %+v", record, got)
+	}
+	if !got.Valid() {
+		t.Fatal("expected the session to be valid")
+	}
+
+	if _, ok, err := s.GetSession("missing"); err != nil || ok {
+		t.Fatalf("expected a missing session, got %v, %v", ok, err)
+	}
+
+	record.Expired = true
+	if err := s.SaveSession(record); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	sessions, err := s.Sessions()
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	if len(sessions) != 1 || !sessions[0].Expired {
+		t.Fatalf("expected the session to be replaced, got %+v", sessions)
+	}
+}
+
+func TestBoltStorePruneUploads(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for hash, age := range map[string]time.Duration{"old": 2 * time.Hour, "fresh": time.Minute} {
+		if err := s.SaveUpload(UploadRecord{Hash: hash, FileUUID: hash + "-file", CreatedAt: now.Add(-age)}); err != nil {
+			t.Fatalf("failed to save upload: %v", err)
+		}
+	}
+
+	deleted, err := s.PruneUploads(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("failed to prune uploads: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 deleted upload, got %d", deleted)
+	}
+	if _, ok, _ := s.GetUpload("old"); ok {
+		t.Fatal("expected the old upload to be pruned")
+	}
+	if record, ok, _ := s.GetUpload("fresh"); !ok || record.FileUUID != "fresh-file" {
+		t.Fatalf("expected the fresh upload to remain, got %+v", record)
+	}
+}
+
+func TestBoltStoreResponses(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := ResponseRecord{
+		ID:        "resp_1",
+		APIKey:    "default",
+		Response:  []byte(`{"id":"resp_1","status":"completed"}`),
+		Messages:  []map[string]interface{}{{"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}},
+		CreatedAt: now,
+	}
+	if err := s.SaveResponse(record); err != nil {
+		t.Fatalf("failed to save response: %v", err)
+	}
+	if err := s.SaveResponse(ResponseRecord{ID: "resp_old", APIKey: "default", CreatedAt: now.Add(-48 * time.Hour)}); err != nil {
+		t.Fatalf("failed to save response: %v", err)
+	}
+
+	got, ok, err := s.GetResponse("resp_1")
+	if err != nil || !ok {
+		t.Fatalf("expected the response to be found, got %v, %v", ok, err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Fatalf("expected %+v, got %+v", record, got)
+	}
+
+	deleted, err := s.PruneResponses(now.Add(-24 * time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 deleted response, got %d, %v", deleted, err)
+	}
+	if _, ok, _ := s.GetResponse("resp_old"); ok {
+		t.Fatal("expected the old response to be pruned")
+	}
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryResponseLimit 为内存中保存的响应数量上限，超出后最旧的响应会被淘汰
+const memoryResponseLimit = 1000
+
+// MemoryStore keeps everything in memory, it is used when STORE_PATH is not set
+type MemoryStore struct {
+	mu            sync.RWMutex
+	sessions      map[string]SessionRecord
+	usage         []UsageRecord
+	conversations map[string]ConversationRecord
+	uploads       map[string]UploadRecord
+	responses     map[string]ResponseRecord
+	// responseOrder 按保存顺序记录响应 ID，用于淘汰最旧的响应
+	responseOrder []string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions:      make(map[string]SessionRecord),
+		usage:         []UsageRecord{},
+		conversations: make(map[string]ConversationRecord),
+		uploads:       make(map[string]UploadRecord),
+		responses:     make(map[string]ResponseRecord),
+	}
+}
+
+func (s *MemoryStore) SaveSession(record SessionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[record.Session] = record
+	return nil
+}
+
+func (s *MemoryStore) GetSession(session string) (SessionRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.sessions[session]
+	return record, ok, nil
+}
+
+func (s *MemoryStore) Sessions() ([]SessionRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]SessionRecord, 0, len(s.sessions))
+	for _, record := range s.sessions {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) AddUsage(record UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 记录通常按时间顺序到达，只有乱序时才需要插入到中间
+	i := sort.Search(len(s.usage), func(i int) bool { return s.usage[i].Time.After(record.Time) })
+	s.usage = append(s.usage, UsageRecord{})
+	copy(s.usage[i+1:], s.usage[i:])
+	s.usage[i] = record
+	return nil
+}
+
+func (s *MemoryStore) Usage(from time.Time, to time.Time) ([]UsageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := []UsageRecord{}
+	for _, record := range s.usage {
+		if !record.Time.Before(from) && record.Time.Before(to) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) PruneUsage(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.usage), func(i int) bool { return !s.usage[i].Time.Before(before) })
+	// 复制剩余记录，释放被删除记录占用的底层数组
+	s.usage = append([]UsageRecord{}, s.usage[i:]...)
+	return i, nil
+}
+
+func (s *MemoryStore) SaveConversation(record ConversationRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conversations[record.UUID] = record
+	return nil
+}
+
+func (s *MemoryStore) DeleteConversation(conversationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conversations, conversationID)
+	return nil
+}
+
+func (s *MemoryStore) Conversations() ([]ConversationRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]ConversationRecord, 0, len(s.conversations))
+	for _, record := range s.conversations {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *MemoryStore) SaveUpload(record UploadRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads[record.Hash] = record
+	return nil
+}
+
+func (s *MemoryStore) GetUpload(hash string) (UploadRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.uploads[hash]
+	return record, ok, nil
+}
+
+func (s *MemoryStore) PruneUploads(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for hash, record := range s.uploads {
+		if record.CreatedAt.Before(before) {
+			delete(s.uploads, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemoryStore) SaveResponse(record ResponseRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.responses[record.ID]; !exists {
+		s.responseOrder = append(s.responseOrder, record.ID)
+	}
+	s.responses[record.ID] = record
+	for len(s.responseOrder) > memoryResponseLimit {
+		delete(s.responses, s.responseOrder[0])
+		s.responseOrder = s.responseOrder[1:]
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetResponse(id string) (ResponseRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.responses[id]
+	return record, ok, nil
+}
+
+func (s *MemoryStore) PruneResponses(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order := []string{}
+	for _, id := range s.responseOrder {
+		if s.responses[id].CreatedAt.Before(before) {
+			delete(s.responses, id)
+		} else {
+			order = append(order, id)
+		}
+	}
+	deleted := len(s.responseOrder) - len(order)
+	s.responseOrder = order
+	return deleted, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SessionRecord 为会话的元数据，重启后无需重新查询组织 ID
+type SessionRecord struct {
+	// Session 为会话池条目 ID 的哈希，不保存原始会话密钥
+	Session   string    `json:"session"`
+	OrgID     string    `json:"org_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// 以下字段为最近一次会话检查的结果
+	OrgName       string    `json:"org_name,omitempty"`
+	RateLimitTier string    `json:"rate_limit_tier,omitempty"`
+	Capabilities  []string  `json:"capabilities,omitempty"`
+	Expired       bool      `json:"expired,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	CheckedAt     time.Time `json:"checked_at,omitempty"`
+}
+
+// Valid reports whether the last check of the session succeeded
+func (r SessionRecord) Valid() bool {
+	return !r.CheckedAt.IsZero() && !r.Expired && r.LastError == ""
+}
+
+// UsageRecord 为一次请求的用量
+type UsageRecord struct {
+	Time time.Time `json:"time"`
+	// APIKey 为 API 密钥的名称，镜像模式下为 mirror
+	APIKey string `json:"api_key"`
+	Model  string `json:"model"`
+	// Session 为脱敏后的会话密钥，按组织选择时带有组织
+	Session          string        `json:"session"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Retries          int           `json:"retries"`
+	Error            bool          `json:"error"`
+	Latency          time.Duration `json:"latency"`
+}
+
+// ConversationRecord 为本服务创建且尚未删除的对话
+type ConversationRecord struct {
+	UUID string `json:"uuid"`
+	// Session 为会话池条目 ID 的哈希，不保存原始会话密钥
+	Session   string    `json:"session"`
+	OrgID     string    `json:"org_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UploadRecord 为已上传文件的缓存，Hash 由组织 ID 和文件内容计算
+type UploadRecord struct {
+	Hash      string    `json:"hash"`
+	FileUUID  string    `json:"file_uuid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ResponseRecord 为 Responses API 保存的响应及其完整的对话历史，供 previous_response_id 继续对话
+type ResponseRecord struct {
+	ID string `json:"id"`
+	// APIKey 为创建响应的 API 密钥名称，只有同一密钥可以读取，镜像模式下带有会话指纹
+	APIKey    string                   `json:"api_key"`
+	Response  json.RawMessage          `json:"response"`
+	Messages  []map[string]interface{} `json:"messages"`
+	CreatedAt time.Time                `json:"created_at"`
+}
+
+// Store persists the state that should survive restarts
+type Store interface {
+	// SaveSession creates or replaces the metadata of a session
+	SaveSession(record SessionRecord) error
+	// GetSession returns the metadata of a session by its fingerprint
+	GetSession(session string) (SessionRecord, bool, error)
+	// Sessions returns the metadata of all sessions
+	Sessions() ([]SessionRecord, error)
+
+	// AddUsage appends a usage record
+	AddUsage(record UsageRecord) error
+	// Usage returns the usage records in [from, to), ordered by time
+	Usage(from time.Time, to time.Time) ([]UsageRecord, error)
+	// PruneUsage deletes the usage records older than before and returns how many were deleted
+	PruneUsage(before time.Time) (int, error)
+
+	// SaveConversation adds a conversation to the ledger
+	SaveConversation(record ConversationRecord) error
+	// DeleteConversation removes a conversation from the ledger
+	DeleteConversation(conversationID string) error
+	// Conversations returns the conversations in the ledger
+	Conversations() ([]ConversationRecord, error)
+
+	// SaveUpload caches an uploaded file
+	SaveUpload(record UploadRecord) error
+	// GetUpload returns a cached upload by hash
+	GetUpload(hash string) (UploadRecord, bool, error)
+	// PruneUploads deletes the uploads cached before before and returns how many were deleted
+	PruneUploads(before time.Time) (int, error)
+
+	// SaveResponse creates or replaces a stored response
+	SaveResponse(record ResponseRecord) error
+	// GetResponse returns a stored response by id
+	GetResponse(id string) (ResponseRecord, bool, error)
+	// PruneResponses deletes the responses stored before before and returns how many were deleted
+	PruneResponses(before time.Time) (int, error)
+
+	Close() error
+}
+
+// Default 为全局使用的存储，未配置 STORE_PATH 时保存在内存中
+var Default Store = NewMemoryStore()
+
+// Open opens the bbolt store at path, an empty path returns an in-memory store
+func Open(path string) (Store, error) {
+	if path == "" {
+		return NewMemoryStore(), nil
+	}
+	return OpenBoltStore(path)
+}
@@ -0,0 +1,402 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var codeFenceRegex = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\\n?(.*?)\\n?```$")
+
+// ExtractJSON 去除回复中的代码块标记和多余文字，返回 JSON 文本
+func ExtractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if match := codeFenceRegex.FindStringSubmatch(text); match != nil {
+		text = strings.TrimSpace(match[1])
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	// 回复中夹杂了说明文字时，截取第一个 { 或 [ 到最后一个 } 或 ] 之间的内容
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end > start {
+		return text[start : end+1]
+	}
+	return text
+}
+
+// ErrInvalidSchema 表示请求中的 schema 本身无法使用，例如 $ref 无法解析或形成循环
+var ErrInvalidSchema = errors.New("invalid JSON schema")
+
+// CheckSchema 在请求开始前检查 schema，$ref 必须能够解析，且不能在不消耗值的情况下
+// 通过 $ref、allOf、anyOf 或 oneOf 回到自身，否则校验时会无限递归
+func CheckSchema(schema map[string]interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	v := &schemaValidator{root: schema}
+
+	// 先收集所有子 schema，记录每个 schema 对同一个值继续校验的子 schema
+	same := make(map[uintptr][]map[string]interface{})
+	nodes := []map[string]interface{}{}
+	pending := []map[string]interface{}{schema}
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		id := reflect.ValueOf(node).Pointer()
+		if _, seen := same[id]; seen {
+			continue
+		}
+		edges := []map[string]interface{}{}
+		if ref, ok := node["$ref"].(string); ok {
+			resolved, err := v.resolve(ref)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+			}
+			edges = append(edges, resolved)
+		}
+		for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+			options, _ := node[keyword].([]interface{})
+			for _, option := range options {
+				if sub, ok := option.(map[string]interface{}); ok {
+					edges = append(edges, sub)
+				}
+			}
+		}
+		same[id] = edges
+		nodes = append(nodes, node)
+		pending = append(pending, edges...)
+		pending = append(pending, childSchemas(node)...)
+	}
+
+	// 在同一个值的校验关系中查找环，子值的递归（如树结构）不受影响
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[uintptr]int)
+	var visit func(node map[string]interface{}) error
+	visit = func(node map[string]interface{}) error {
+		id := reflect.ValueOf(node).Pointer()
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("%w: cyclic $ref", ErrInvalidSchema)
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		for _, next := range same[id] {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		state[id] = done
+		return nil
+	}
+	for _, node := range nodes {
+		if err := visit(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// childSchemas 返回用于校验子值或定义在 schema 中的子 schema
+func childSchemas(node map[string]interface{}) []map[string]interface{} {
+	children := []map[string]interface{}{}
+	for _, keyword := range []string{"properties", "$defs", "definitions"} {
+		if object, ok := node[keyword].(map[string]interface{}); ok {
+			for _, item := range object {
+				if sub, ok := item.(map[string]interface{}); ok {
+					children = append(children, sub)
+				}
+			}
+		}
+	}
+	for _, keyword := range []string{"items", "additionalProperties"} {
+		if sub, ok := node[keyword].(map[string]interface{}); ok {
+			children = append(children, sub)
+		}
+	}
+	return children
+}
+
+// ValidateJSON 校验 JSON 文本，schema 为空时只检查是否为合法 JSON
+func ValidateJSON(text string, schema map[string]interface{}) error {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+	if schema == nil {
+		return nil
+	}
+	if err := CheckSchema(schema); err != nil {
+		return err
+	}
+	v := &schemaValidator{root: schema}
+	return v.validate(value, schema, "$", 0)
+}
+
+// maxSchemaHops 限制同一个值上连续跟随 $ref 和组合关键字的次数，作为 CheckSchema 之外的保护
+const maxSchemaHops = 64
+
+// schemaValidator 实现 JSON Schema 的常用子集
+type schemaValidator struct {
+	root map[string]interface{}
+}
+
+// hops 为当前值上已经跟随的 $ref 和组合关键字次数，校验子值时重新计数
+func (v *schemaValidator) validate(value interface{}, schema map[string]interface{}, path string, hops int) error {
+	if hops > maxSchemaHops {
+		return fmt.Errorf("%w: %s: $ref nested too deeply", ErrInvalidSchema, path)
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return v.validate(value, resolved, path, hops+1)
+	}
+
+	if types, ok := schema["type"]; ok && !matchesType(value, types) {
+		return fmt.Errorf("%s: expected type %v, got %s", path, types, jsonType(value))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(value, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of %v", path, enum)
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(value, constant) {
+		return fmt.Errorf("%s: value must be %v", path, constant)
+	}
+
+	if err := v.validateCombinators(value, schema, path, hops); err != nil {
+		return err
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		return v.validateObject(val, schema, path)
+	case []interface{}:
+		return v.validateArray(val, schema, path)
+	case string:
+		length := utf8.RuneCountInString(val)
+		if min, ok := schemaNumber(schema, "minLength"); ok && float64(length) < min {
+			return fmt.Errorf("%s: string shorter than %v", path, min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && float64(length) > max {
+			return fmt.Errorf("%s: string longer than %v", path, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err == nil && !re.MatchString(val) {
+				return fmt.Errorf("%s: string does not match pattern %s", path, pattern)
+			}
+		}
+	case json.Number:
+		number, _ := val.Float64()
+		if min, ok := schemaNumber(schema, "minimum"); ok && number < min {
+			return fmt.Errorf("%s: %v is less than minimum %v", path, number, min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && number > max {
+			return fmt.Errorf("%s: %v is greater than maximum %v", path, number, max)
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) validateCombinators(value interface{}, schema map[string]interface{}, path string, hops int) error {
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				if err := v.validate(value, subSchema, path, hops+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		options, ok := schema[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, sub := range options {
+			subSchema, ok := sub.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			err := v.validate(value, subSchema, path, hops+1)
+			if errors.Is(err, ErrInvalidSchema) {
+				return err
+			}
+			if err == nil {
+				matched++
+			}
+		}
+		if matched == 0 || (keyword == "oneOf" && matched > 1) {
+			return fmt.Errorf("%s: value does not match %s", path, keyword)
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) validateObject(value map[string]interface{}, schema map[string]interface{}, path string) error {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := value[key]; !exists {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for key, item := range value {
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			if err := v.validate(item, propSchema, path+"."+key, 0); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property %q", path, key)
+			}
+		case map[string]interface{}:
+			if err := v.validate(item, additional, path+"."+key, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) validateArray(value []interface{}, schema map[string]interface{}, path string) error {
+	if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(value)) < min {
+		return fmt.Errorf("%s: array has fewer than %v items", path, min)
+	}
+	if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(value)) > max {
+		return fmt.Errorf("%s: array has more than %v items", path, max)
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range value {
+			if err := v.validate(item, items, fmt.Sprintf("%s[%d]", path, i), 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve 只支持文档内部引用，例如 #/$defs/Item
+func (v *schemaValidator) resolve(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %s", ref)
+	}
+	var node interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+		node = object[part]
+	}
+	resolved, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %s", ref)
+	}
+	return resolved, nil
+}
+
+func matchesType(value interface{}, types interface{}) bool {
+	switch t := types.(type) {
+	case string:
+		return matchesSingleType(value, t)
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok && matchesSingleType(value, name) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(value interface{}, typeName string) bool {
+	actual := jsonType(value)
+	if typeName == "number" && actual == "integer" {
+		return true
+	}
+	return actual == typeName
+}
+
+func jsonType(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if number, err := val.Float64(); err == nil && number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func jsonEqual(a interface{}, b interface{}) bool {
+	if number, ok := a.(json.Number); ok {
+		value, _ := number.Float64()
+		switch other := b.(type) {
+		case float64:
+			return value == other
+		case json.Number:
+			otherValue, _ := other.Float64()
+			return value == otherValue
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	switch n := schema[key].(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		value, err := n.Float64()
+		return value, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func parseSchema(t *testing.T, text string) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &schema); err != nil {
+		t.Fatalf("invalid test schema %s: %v", text, err)
+	}
+	return schema
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		invalid bool
+	}{
+		{"plain object", `{"type":"object","properties":{"a":{"type":"string"}}}`, false},
+		{"self reference", `{"$ref":"#"}`, true},
+		{"cyclic defs", `{"$defs":{"A":{"$ref":"#/$defs/A"}},"$ref":"#/$defs/A"}`, true},
+		{"unused cyclic defs", `{"$defs":{"A":{"$ref":"#/$defs/B"},"B":{"$ref":"#/$defs/A"}}}`, true},
+		{"cycle through allOf", `{"$defs":{"A":{"allOf":[{"$ref":"#/$defs/A"}]}},"$ref":"#/$defs/A"}`, true},
+		{"cycle through anyOf", `{"anyOf":[{"type":"string"},{"$ref":"#"}]}`, true},
+		{"unresolvable ref", `{"$ref":"#/$defs/Missing"}`, true},
+		{"external ref", `{"$ref":"https://example.com/schema.json"}`, true},
+		{"recursive tree", `{"$defs":{"Node":{"type":"object","properties":{"children":{"type":"array","items":{"$ref":"#/$defs/Node"}}}}},"$ref":"#/$defs/Node"}`, false},
+		{"recursive property", `{"type":"object","properties":{"next":{"$ref":"#"}}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSchema(parseSchema(t, tt.schema))
+			if tt.invalid && !errors.Is(err, ErrInvalidSchema) {
+				t.Fatalf("expected ErrInvalidSchema, got %v", err)
+			}
+			if !tt.invalid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		value  string
+		valid  bool
+	}{
+		{"invalid json", ``, `{"a":`, false},
+		{"trailing data", ``, `{} {}`, false},
+		{"no schema", ``, `[1,2]`, true},
+
+		{"integer is a number", `{"type":"number"}`, `3`, true},
+		{"float is a number", `{"type":"number"}`, `3.5`, true},
+		{"integer", `{"type":"integer"}`, `3`, true},
+		{"whole float is an integer", `{"type":"integer"}`, `3.0`, true},
+		{"float is not an integer", `{"type":"integer"}`, `3.5`, false},
+		{"string is not a number", `{"type":"number"}`, `"3"`, false},
+		{"type list", `{"type":["string","null"]}`, `null`, true},
+		{"minimum", `{"type":"number","minimum":1}`, `0.5`, false},
+		{"maximum", `{"type":"integer","maximum":10}`, `10`, true},
+
+		{"oneOf single match", `{"oneOf":[{"type":"string"},{"type":"integer"}]}`, `1`, true},
+		{"oneOf no match", `{"oneOf":[{"type":"string"},{"type":"integer"}]}`, `true`, false},
+		{"oneOf multiple matches", `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, `1`, false},
+		{"anyOf match", `{"anyOf":[{"type":"string"},{"type":"boolean"}]}`, `true`, true},
+		{"anyOf no match", `{"anyOf":[{"type":"string"},{"type":"boolean"}]}`, `1`, false},
+		{"allOf all match", `{"allOf":[{"type":"integer"},{"minimum":2}]}`, `3`, true},
+		{"allOf one fails", `{"allOf":[{"type":"integer"},{"minimum":5}]}`, `3`, false},
+
+		{"additionalProperties false", `{"type":"object","properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, false},
+		{"additionalProperties true", `{"type":"object","properties":{"a":{}},"additionalProperties":true}`, `{"a":1,"b":2}`, true},
+		{"additionalProperties schema", `{"type":"object","additionalProperties":{"type":"string"}}`, `{"a":"x","b":2}`, false},
+		{"required", `{"type":"object","required":["a"]}`, `{"b":1}`, false},
+
+		{"ref", `{"$defs":{"S":{"type":"string"}},"type":"array","items":{"$ref":"#/$defs/S"}}`, `["a",1]`, false},
+		{"recursive tree", `{"$defs":{"Node":{"type":"object","properties":{"children":{"type":"array","items":{"$ref":"#/$defs/Node"}}}}},"$ref":"#/$defs/Node"}`, `{"children":[{"children":[]}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema map[string]interface{}
+			if tt.schema != "" {
+				schema = parseSchema(t, tt.schema)
+			}
+			err := ValidateJSON(tt.value, schema)
+			if tt.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected validation error")
+			}
+		})
+	}
+}
+
+func TestValidateJSONCyclicSchema(t *testing.T) {
+	for _, schema := range []string{`{"$ref":"#"}`, `{"$defs":{"A":{"$ref":"#/$defs/A"}},"$ref":"#/$defs/A"}`} {
+		err := ValidateJSON(`{}`, parseSchema(t, schema))
+		if !errors.Is(err, ErrInvalidSchema) {
+			t.Fatalf("schema %s: expected ErrInvalidSchema, got %v", schema, err)
+		}
+	}
+}
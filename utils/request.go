@@ -13,6 +13,17 @@ type ChatRequestProcessor struct {
 	Prompt      strings.Builder
 	RootPrompt  strings.Builder
 	ImgDataList []string
+	// Instructions 为附加在提示词末尾的系统指令，使用文件上下文时会重新附加
+	Instructions []string
+	// Template 用于渲染提示词，默认使用全局配置的模板
+	Template *PromptTemplate
+	messages []PromptMessage
+}
+
+// ContextFile 为提示词过长时随消息发送的上下文附件
+type ContextFile struct {
+	Name    string
+	Content string
 }
 
 // NewChatRequestProcessor creates a new processor instance
@@ -21,15 +32,12 @@ func NewChatRequestProcessor() *ChatRequestProcessor {
 		Prompt:      strings.Builder{},
 		RootPrompt:  strings.Builder{},
 		ImgDataList: []string{},
+		Template:    GetPromptTemplate(config.ConfigInstance.PromptTemplate),
 	}
 }
 
 // ProcessMessages processes the messages array into a prompt and extracts images
 func (p *ChatRequestProcessor) ProcessMessages(messages []map[string]interface{}) {
-	if config.ConfigInstance.PromptDisableArtifacts {
-		p.Prompt.WriteString("System: Forbidden to use <antArtifac> </antArtifac> to wrap code blocks, use markdown syntax instead, which means wrapping code blocks with ``` ```\n\n")
-	}
-
 	for _, msg := range messages {
 		role, roleOk := msg["role"].(string)
 		if !roleOk {
@@ -41,23 +49,23 @@ func (p *ChatRequestProcessor) ProcessMessages(messages []map[string]interface{}
 			continue
 		}
 
-		p.Prompt.WriteString(GetRolePrefix(role))
-
+		message := PromptMessage{Role: role, Parts: []string{}}
 		switch v := content.(type) {
 		case string: // If content is directly a string
-			p.Prompt.WriteString(v + "\n\n")
+			message.Parts = append(message.Parts, v)
 		case []interface{}: // If content is an array of []interface{} type
 			for _, item := range v {
 				if itemMap, ok := item.(map[string]interface{}); ok {
 					if itemType, ok := itemMap["type"].(string); ok {
 						if itemType == "text" {
 							if text, ok := itemMap["text"].(string); ok {
-								p.Prompt.WriteString(text + "\n\n")
+								message.Parts = append(message.Parts, text)
 							}
 						} else if itemType == "image_url" {
 							if imageUrl, ok := itemMap["image_url"].(map[string]interface{}); ok {
 								if url, ok := imageUrl["url"].(string); ok {
 									p.ImgDataList = append(p.ImgDataList, url)
+									message.Images++
 								}
 							}
 						}
@@ -65,18 +73,202 @@ func (p *ChatRequestProcessor) ProcessMessages(messages []map[string]interface{}
 				}
 			}
 		}
+		message.Content = strings.Join(message.Parts, "\n\n")
+		p.messages = append(p.messages, message)
 	}
+	p.Prompt.WriteString(p.Template.render("prompt", p.promptData(p.messages)))
 	p.RootPrompt.WriteString(p.Prompt.String())
 	// Debug output
 	logger.Debug(fmt.Sprintf("Processed prompt: %s", p.Prompt.String()))
 	logger.Debug(fmt.Sprintf("Image data list: %v", p.ImgDataList))
 }
 
+// Overflows reports whether the prompt is too long to send inline, measured
+// in estimated tokens when CONTEXT_MAX_TOKENS is set and in bytes otherwise
+func (p *ChatRequestProcessor) Overflows() bool {
+	return overflows(p.Prompt.String())
+}
+
+func overflows(prompt string) bool {
+	if config.ConfigInstance.ContextMaxTokens > 0 {
+		return EstimateTokens(prompt) > config.ConfigInstance.ContextMaxTokens
+	}
+	return len(prompt) > config.ConfigInstance.MaxChatHistoryLength
+}
+
+// FitContext rewrites an overflowing prompt with the given strategy and
+// returns the files to attach along with the strategy actually applied. Strategies:
+//
+//	attachment  整段提示词放入 context.txt
+//	keep_recent 系统消息和最近的消息保留在提示词中，较早的消息放入 context.txt
+//	truncate    丢弃最早的消息直到提示词不再超长，不使用附件
+//	split       按 CONTEXT_SPLIT_TOKENS 将对话拆分为多个附件
+//
+// keep_recent 和 truncate 无法缩短到阈值以内时退回 attachment
+func (p *ChatRequestProcessor) FitContext(strategy string) ([]ContextFile, string) {
+	switch strategy {
+	case "keep_recent":
+		if files, ok := p.keepRecent(config.ConfigInstance.ContextKeepMessages); ok {
+			return files, strategy
+		}
+	case "truncate":
+		if p.truncate() {
+			return nil, strategy
+		}
+	case "split":
+		return p.split(config.ConfigInstance.ContextSplitTokens), strategy
+	}
+	files := []ContextFile{{Name: "context.txt", Content: p.Prompt.String()}}
+	p.ResetForBigContext()
+	return files, "attachment"
+}
+
 // ResetForBigContext resets the prompt for big context usage
 func (p *ChatRequestProcessor) ResetForBigContext() {
+	p.resetWithFiles([]string{"context.txt"}, nil)
+}
+
+// resetWithFiles 将提示词替换为 big_context 模板，recent 为保留在提示词中的消息
+func (p *ChatRequestProcessor) resetWithFiles(files []string, recent []PromptMessage) {
+	data := p.promptData(recent)
+	data.Instructions = p.Instructions
+	data.Files = files
+	p.Prompt.Reset()
+	p.Prompt.WriteString(p.Template.render("big_context", data))
+}
+
+func (p *ChatRequestProcessor) keepRecent(keep int) ([]ContextFile, bool) {
+	older, recent := []PromptMessage{}, []PromptMessage{}
+	kept := 0
+	for i := len(p.messages) - 1; i >= 0; i-- {
+		message := p.messages[i]
+		if message.Role == "system" || kept < keep {
+			if message.Role != "system" {
+				kept++
+			}
+			recent = append([]PromptMessage{message}, recent...)
+			continue
+		}
+		older = append([]PromptMessage{message}, older...)
+	}
+	if len(older) == 0 {
+		return nil, false
+	}
+	content := p.Template.render("messages", p.promptData(older))
+	p.resetWithFiles([]string{"context.txt"}, recent)
+	if overflows(p.Prompt.String()) {
+		p.resetPrompt()
+		return nil, false
+	}
+	return []ContextFile{{Name: "context.txt", Content: content}}, true
+}
+
+func (p *ChatRequestProcessor) truncate() bool {
+	messages := append([]PromptMessage{}, p.messages...)
+	for {
+		// 删除最早的一条非系统消息，最后一条消息始终保留
+		dropped := false
+		for i := 0; i < len(messages)-1; i++ {
+			if messages[i].Role != "system" {
+				messages = append(messages[:i], messages[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			p.resetPrompt()
+			return false
+		}
+		prompt := p.renderPrompt(messages)
+		if !overflows(prompt) {
+			p.Prompt.Reset()
+			p.Prompt.WriteString(prompt)
+			logger.Info(fmt.Sprintf("Truncated %d of %d messages to fit the context", len(p.messages)-len(messages), len(p.messages)))
+			return true
+		}
+	}
+}
+
+func (p *ChatRequestProcessor) split(maxTokens int) []ContextFile {
+	chunks := []string{}
+	var current strings.Builder
+	var counter TokenCounter
+	for _, message := range p.messages {
+		text := p.Template.render("messages", p.promptData([]PromptMessage{message}))
+		// 尽量在消息之间拆分，只有单条消息超过附件上限时才拆开
+		if current.Len() > 0 && counter.Tokens()+EstimateTokens(text) > maxTokens {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			counter = TokenCounter{}
+		}
+		for text != "" {
+			fitted, truncated := counter.Fit(text, maxTokens)
+			current.WriteString(fitted)
+			text = text[len(fitted):]
+			if truncated {
+				// 当前附件已满，消息剩余部分放入下一个附件
+				chunks = append(chunks, current.String())
+				current.Reset()
+				counter = TokenCounter{}
+			}
+		}
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	files := []ContextFile{}
+	names := []string{}
+	for i, chunk := range chunks {
+		name := "context.txt"
+		if len(chunks) > 1 {
+			name = fmt.Sprintf("context_%d.txt", i+1)
+		}
+		files = append(files, ContextFile{Name: name, Content: chunk})
+		names = append(names, name)
+	}
+	p.resetWithFiles(names, nil)
+	return files
+}
+
+// renderPrompt 使用 prompt 模板渲染消息，并附加系统指令
+func (p *ChatRequestProcessor) renderPrompt(messages []PromptMessage) string {
+	var sb strings.Builder
+	sb.WriteString(p.Template.render("prompt", p.promptData(messages)))
+	for _, instruction := range p.Instructions {
+		sb.WriteString(p.Template.render("instruction", instruction))
+	}
+	return sb.String()
+}
+
+// resetPrompt 恢复为完整的提示词
+func (p *ChatRequestProcessor) resetPrompt() {
 	p.Prompt.Reset()
-	if config.ConfigInstance.PromptDisableArtifacts {
-		p.Prompt.WriteString("System: Forbidden to use <antArtifac> </antArtifac> to wrap code blocks, use markdown syntax instead, which means wrapping code blocks with ``` ```\n\n")
+	p.Prompt.WriteString(p.RootPrompt.String())
+}
+
+// AppendSystemInstruction appends a system instruction to the end of the prompt
+func (p *ChatRequestProcessor) AppendSystemInstruction(instruction string) {
+	p.Instructions = append(p.Instructions, instruction)
+	rendered := p.Template.render("instruction", instruction)
+	p.Prompt.WriteString(rendered)
+	p.RootPrompt.WriteString(rendered)
+}
+
+func (p *ChatRequestProcessor) promptData(messages []PromptMessage) PromptData {
+	data := PromptData{
+		Messages:         []PromptMessage{},
+		System:           []PromptMessage{},
+		Conversation:     []PromptMessage{},
+		DisableArtifacts: config.ConfigInstance.PromptDisableArtifacts,
+	}
+	for _, message := range messages {
+		data.Messages = append(data.Messages, message)
+		if message.Role == "system" {
+			data.System = append(data.System, message)
+		} else {
+			data.Conversation = append(data.Conversation, message)
+		}
 	}
-	p.Prompt.WriteString("You must immerse yourself in the role of assistant in context.txt, cannot respond as a user, cannot reply to this message, cannot mention this message, and ignore this message in your response.\n\n")
+	return data
 }
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"claude2api/logger"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// 提示词模板由以下几个命名模板组成，自定义模板文件只需定义需要覆盖的部分：
+//
+//	preamble    提示词开头的内容，默认在 PROMPT_DISABLE_ARTIFACTS 时禁止 artifacts
+//	messages    渲染消息列表，数据为 PromptData
+//	prompt      渲染整段提示词，默认为 preamble 加 messages
+//	instruction 渲染追加的系统指令，数据为指令文本
+//	big_context 使用上下文附件时的提示词，.Files 为附件名，.Messages 为保留在提示词中的消息
+const defaultTemplate = `{{define "preamble"}}{{if .DisableArtifacts}}System: Forbidden to use <antArtifac> </antArtifac> to wrap code blocks, use markdown syntax instead, which means wrapping code blocks with ` + "``` ```" + `
+
+{{end}}{{end}}
+{{- define "messages"}}{{range .Messages}}{{rolePrefix .Role}}{{range .Parts}}{{.}}
+
+{{end}}{{end}}{{end}}
+{{- define "prompt"}}{{template "preamble" .}}{{template "messages" .}}{{end}}
+{{- define "instruction"}}{{rolePrefix "system"}}{{.}}
+
+{{end}}
+{{- define "big_context"}}{{template "preamble" .}}{{if .Messages}}The earlier conversation is in {{join .Files ", "}}. Continue the conversation below as the assistant, do not mention the attachment or this message in your response.
+
+{{template "messages" .}}{{else}}You must immerse yourself in the role of assistant in {{join .Files ", "}}, cannot respond as a user, cannot reply to this message, cannot mention this message, and ignore this message in your response.
+
+{{end}}{{range .Instructions}}{{template "instruction" .}}{{end}}{{end}}`
+
+// noPrefixTemplate 与 NO_ROLE_PREFIX=true 的行为一致
+const noPrefixTemplate = `{{define "messages"}}{{range .Messages}}{{range .Parts}}{{.}}
+
+{{end}}{{end}}{{end}}
+{{- define "instruction"}}{{.}}
+
+{{end}}`
+
+// plainTemplate 将系统提示放在开头，对话使用 User / Assistant 标记
+const plainTemplate = `{{define "messages"}}{{range .System}}{{.Content}}
+
+{{end}}{{range .Conversation}}{{title .Role}}: {{.Content}}
+
+{{end}}{{end}}
+{{- define "instruction"}}{{.}}
+
+{{end}}`
+
+// xmlTemplate 使用 XML 标签区分角色
+const xmlTemplate = `{{define "preamble"}}{{if .DisableArtifacts}}<system>
+Do not use <antArtifact> tags to wrap code blocks, use markdown code fences instead.
+</system>
+
+{{end}}{{end}}
+{{- define "messages"}}{{range .System}}<system>
+{{.Content}}
+</system>
+
+{{end}}{{range .Conversation}}<{{.Role}}>
+{{.Content}}
+</{{.Role}}>
+
+{{end}}{{end}}
+{{- define "instruction"}}<system>
+{{.}}
+</system>
+
+{{end}}
+{{- define "big_context"}}{{template "preamble" .}}<system>
+{{if .Messages}}The earlier conversation is in {{join .Files ", "}}, each turn wrapped in <user> or <assistant> tags. Continue the conversation below{{else}}The conversation so far is in {{join .Files ", "}}, each turn wrapped in <user> or <assistant> tags{{end}}. Write the next <assistant> reply only. Do not mention the attachment or this message.
+</system>
+
+{{template "messages" .}}{{range .Instructions}}{{template "instruction" .}}{{end}}{{end}}`
+
+// promptPresets 为内置的模板预设，均基于 default 覆盖
+var promptPresets = map[string]string{
+	"default":   "",
+	"no_prefix": noPrefixTemplate,
+	"plain":     plainTemplate,
+	"xml":       xmlTemplate,
+}
+
+var templateFuncs = template.FuncMap{
+	"rolePrefix": GetRolePrefix,
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"join":  strings.Join,
+}
+
+// PromptMessage 为模板中的一条消息，Parts 为按顺序排列的文本内容
+type PromptMessage struct {
+	Role    string
+	Content string
+	Parts   []string
+	Images  int
+}
+
+// PromptData 为 messages、prompt 和 big_context 模板的数据
+type PromptData struct {
+	// Messages 为全部消息，System 和 Conversation 分别为系统消息和其余消息
+	Messages         []PromptMessage
+	System           []PromptMessage
+	Conversation     []PromptMessage
+	Instructions     []string
+	Files            []string
+	DisableArtifacts bool
+}
+
+// PromptTemplate 为解析后的提示词模板
+type PromptTemplate struct {
+	Name     string
+	template *template.Template
+}
+
+var (
+	baseTemplate   = template.Must(template.New("default").Funcs(templateFuncs).Parse(defaultTemplate))
+	templateCache  = map[string]*PromptTemplate{}
+	templateLocker sync.Mutex
+)
+
+// GetPromptTemplate 按预设名称或模板文件路径获取模板，加载失败时使用 default 预设
+func GetPromptTemplate(name string) *PromptTemplate {
+	if name == "" {
+		name = "default"
+	}
+	templateLocker.Lock()
+	defer templateLocker.Unlock()
+	if cached, ok := templateCache[name]; ok {
+		return cached
+	}
+	promptTemplate, err := loadPromptTemplate(name)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load prompt template %s: %v, using default", name, err))
+		promptTemplate = &PromptTemplate{Name: "default", template: baseTemplate}
+	}
+	templateCache[name] = promptTemplate
+	return promptTemplate
+}
+
+func loadPromptTemplate(name string) (*PromptTemplate, error) {
+	text, ok := promptPresets[name]
+	if !ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	tmpl, err := baseTemplate.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.Parse(text); err != nil {
+		return nil, err
+	}
+	return &PromptTemplate{Name: name, template: tmpl}, nil
+}
+
+// render 执行命名模板，失败时改用 default 预设
+func (t *PromptTemplate) render(name string, data interface{}) string {
+	var sb strings.Builder
+	if err := t.template.ExecuteTemplate(&sb, name, data); err != nil {
+		logger.Error(fmt.Sprintf("Failed to render prompt template %s/%s: %v", t.Name, name, err))
+		sb.Reset()
+		baseTemplate.ExecuteTemplate(&sb, name, data)
+	}
+	return sb.String()
+}
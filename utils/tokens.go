@@ -0,0 +1,49 @@
+package utils
+
+import "unicode/utf8"
+
+// TokenCounter 粗略估算文本的 token 数：CJK 等宽字符每个计 1 个，其余字符每 4 个计 1 个
+type TokenCounter struct {
+	wide  int
+	other int
+}
+
+// EstimateTokens 估算一段文本的 token 数
+func EstimateTokens(text string) int {
+	var counter TokenCounter
+	counter.Add(text)
+	return counter.Tokens()
+}
+
+// Add 累加文本
+func (t *TokenCounter) Add(text string) {
+	for _, r := range text {
+		t.addRune(r)
+	}
+}
+
+// Tokens 返回当前估算的 token 数
+func (t *TokenCounter) Tokens() int {
+	return t.wide + (t.other+3)/4
+}
+
+// Fit 在不超过 budget 的前提下尽可能多地累加文本，返回被接受的前缀以及是否发生了截断
+func (t *TokenCounter) Fit(text string, budget int) (string, bool) {
+	for i, r := range text {
+		saved := *t
+		t.addRune(r)
+		if t.Tokens() > budget {
+			*t = saved
+			return text[:i], true
+		}
+	}
+	return text, false
+}
+
+func (t *TokenCounter) addRune(r rune) {
+	if r >= 0x2E80 && utf8.RuneLen(r) > 1 {
+		t.wide++
+		return
+	}
+	t.other++
+}